@@ -13,3 +13,11 @@ type AppService struct {
 	client *Client
 	Slug   string
 }
+
+// App returns the service of the already registered app.
+func (s *AppsService) App(slug string) *AppService {
+	return &AppService{
+		client: s.client,
+		Slug:   slug,
+	}
+}
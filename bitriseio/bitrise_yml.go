@@ -26,3 +26,21 @@ func (s *AppService) UploadBitriseYML(config string) error {
 	}
 	return s.client.do(req, nil)
 }
+
+// BitriseYMLConfigURL ...
+func BitriseYMLConfigURL(appSlug string) string {
+	return fmt.Sprintf(AppsServiceURL+"%s/bitrise.yml/config", appSlug)
+}
+
+// UseRepositoryBitriseYML switches the app to read the bitrise.yml from the repository instead of the copy stored on bitrise.io.
+func (s *AppService) UseRepositoryBitriseYML() error {
+	type BitriseYMLConfigParams struct {
+		IsYMLStoredOnWebsite bool `json:"is_yml_stored_on_website"`
+	}
+
+	req, err := s.client.newRequest(http.MethodPut, BitriseYMLConfigURL(s.Slug), BitriseYMLConfigParams{})
+	if err != nil {
+		return err
+	}
+	return s.client.do(req, nil)
+}
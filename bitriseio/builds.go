@@ -2,7 +2,19 @@ package bitriseio
 
 import (
 	"fmt"
+	"io"
 	"net/http"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// Build statuses
+const (
+	BuildStatusRunning            = 0
+	BuildStatusSuccess            = 1
+	BuildStatusFailed             = 2
+	BuildStatusAbortedWithFailure = 3
+	BuildStatusAbortedWithSuccess = 4
 )
 
 // TriggerBuildURL ...
@@ -10,31 +22,142 @@ func TriggerBuildURL(appSlug string) string {
 	return fmt.Sprintf(AppsServiceURL+"%s/builds", appSlug)
 }
 
+// BuildURL ...
+func BuildURL(appSlug, buildSlug string) string {
+	return fmt.Sprintf("%s/%s", TriggerBuildURL(appSlug), buildSlug)
+}
+
+// BuildLogURL ...
+func BuildLogURL(appSlug, buildSlug string) string {
+	return BuildURL(appSlug, buildSlug) + "/log"
+}
+
+// TriggerBuildParams ...
+// Either the workflow or the pipeline is run by the build.
+type TriggerBuildParams struct {
+	WorkflowID string `json:"workflow_id,omitempty"`
+	PipelineID string `json:"pipeline_id,omitempty"`
+	Branch     string `json:"branch"`
+}
+
+// TriggerBuildResponse ...
+type TriggerBuildResponse struct {
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	BuildSlug   string `json:"build_slug"`
+	BuildNumber int    `json:"build_number"`
+	BuildURL    string `json:"build_url"`
+}
+
 // TriggerBuild ...
-func (s *AppService) TriggerBuild(workflowID, branch string) error {
+func (s *AppService) TriggerBuild(buildParams TriggerBuildParams) (*TriggerBuildResponse, error) {
+	if (buildParams.WorkflowID == "") == (buildParams.PipelineID == "") {
+		return nil, fmt.Errorf("either a workflow or a pipeline is required to trigger a build")
+	}
+
 	type HookInfo struct {
 		Type string `json:"type"`
 	}
-	type BuildParams struct {
-		WorkflowID string `json:"workflow_id"`
-		Branch     string `json:"branch"`
-	}
 	type Params struct {
-		BuildParams BuildParams `json:"build_params"`
-		HookInfo    HookInfo    `json:"hook_info"`
+		BuildParams TriggerBuildParams `json:"build_params"`
+		HookInfo    HookInfo           `json:"hook_info"`
 	}
 	p := Params{
-		BuildParams: BuildParams{
-			WorkflowID: workflowID,
-			Branch:     branch,
-		},
+		BuildParams: buildParams,
 		HookInfo: HookInfo{
 			Type: "bitrise",
 		},
 	}
 	req, err := s.client.newRequest(http.MethodPost, TriggerBuildURL(s.Slug), p)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var resp TriggerBuildResponse
+	if err := s.client.do(req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Build ...
+type Build struct {
+	Slug        string `json:"slug"`
+	BuildNumber int    `json:"build_number"`
+	Status      int    `json:"status"`
+	StatusText  string `json:"status_text"`
+	FinishedAt  string `json:"finished_at"`
+}
+
+// IsFinished ...
+func (b Build) IsFinished() bool {
+	return b.Status != BuildStatusRunning
+}
+
+// IsSuccessful ...
+func (b Build) IsSuccessful() bool {
+	return b.Status == BuildStatusSuccess
+}
+
+// Build returns the current state of the build.
+func (s *AppService) Build(buildSlug string) (*Build, error) {
+	req, err := s.client.newRequest(http.MethodGet, BuildURL(s.Slug, buildSlug), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data Build `json:"data"`
+	}
+	if err := s.client.do(req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// BuildLogChunk ...
+type BuildLogChunk struct {
+	Chunk    string `json:"chunk"`
+	Position int    `json:"position"`
+}
+
+// BuildLog ...
+// The chunks of running builds are listed, the log of finished builds is archived and can be downloaded from the raw log URL.
+type BuildLog struct {
+	LogChunks         []BuildLogChunk `json:"log_chunks"`
+	IsArchived        bool            `json:"is_archived"`
+	ExpiringRawLogURL string          `json:"expiring_raw_log_url"`
+}
+
+// BuildLog returns the latest log chunks of the build.
+func (s *AppService) BuildLog(buildSlug string) (*BuildLog, error) {
+	req, err := s.client.newRequest(http.MethodGet, BuildLogURL(s.Slug, buildSlug), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp BuildLog
+	if err := s.client.do(req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DownloadBuildLog downloads the archived log of the build from its expiring raw log URL.
+func (s *AppService) DownloadBuildLog(rawLogURL string) ([]byte, error) {
+	// the URL is presigned, the API token is not sent to the storage
+	resp, err := s.client.client.Get(rawLogURL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Debugf("Failed to close response body: %s", err)
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to download build log, status code: %d", resp.StatusCode)
 	}
-	return s.client.do(req, nil)
+	return io.ReadAll(resp.Body)
 }
@@ -5,28 +5,6 @@ import (
 	"net/http"
 )
 
-// configByProjectType maps default config names to project types.
-// Register finish endpoint is prepared for the use case of the bitrise.io website's frontend,
-// where the user can let the scanner to generate a scan result or use 'manual' config,
-// in which case the user selects one of our static default configs.
-// Since in case of local project registration the frontend is not involved, we can use only the 'manual' config
-// and select any of the give project type's default configs.
-// Later the tool updated the project's bitrise.yml by calling the '/apps/slug/bitrise.yml' endpoint.
-var configByProjectType = map[string]string{
-	"android":              "default-android-config",
-	"cordova":              "default-cordova-config",
-	"fastlane":             "default-fastlane-android-config",
-	"flutter":              "flutter-config-test-android-2",
-	"ionic":                "default-ionic-config",
-	"ios":                  "default-ios-config",
-	"macos":                "default-macos-config",
-	"react-native":         "default-react-native-config",
-	"kotlin-multiplatform": "default-kotlin-multiplatform-config",
-	"node-js":              "default-node-js-npm-config",
-	"java":                 "default-java-gradle-config",
-	"other":                "other-config",
-}
-
 // RegisterFinishParams ...
 type RegisterFinishParams struct {
 	ProjectType string         `json:"project_type"`
@@ -49,11 +27,6 @@ func RegisterFinishURL(appSlug string) string {
 
 // RegisterFinish ...
 func (s *AppService) RegisterFinish(params RegisterFinishParams) (*RegisterFinishResponse, error) {
-	config, ok := configByProjectType[params.ProjectType]
-	if !ok {
-		return nil, fmt.Errorf("failed to select default config: unkown project type: %s", params.ProjectType)
-	}
-
 	type Params struct {
 		RegisterFinishParams
 		Config string `json:"config"`
@@ -61,7 +34,8 @@ func (s *AppService) RegisterFinish(params RegisterFinishParams) (*RegisterFinis
 	}
 	p := Params{RegisterFinishParams: params}
 	p.Mode = "manual"
-	p.Config = config
+	// the app is already created at this point, unknown project types get the generic default config instead of failing
+	p.Config = GetProjectType(params.ProjectType).DefaultConfig
 
 	req, err := s.client.newRequest(http.MethodPost, RegisterFinishURL(s.Slug), p)
 	if err != nil {
@@ -0,0 +1,97 @@
+package bitriseio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// UploadGenericProjectFileParams ...
+type UploadGenericProjectFileParams struct {
+	// UserEnvKey is exposed to the builds as BITRISEIO_<UserEnvKey>_URL, the download URL of the file.
+	UserEnvKey string `json:"user_env_key"`
+}
+
+// UploadGenericProjectFileURL ...
+func UploadGenericProjectFileURL(appSlug string) string {
+	return fmt.Sprintf(AppsServiceURL+"%s/generic-project-files", appSlug)
+}
+
+// UploadGenericProjectFileConfirmURL ...
+func UploadGenericProjectFileConfirmURL(appSlug, uploadSlug string) string {
+	return fmt.Sprintf("%s/%s/uploaded", UploadGenericProjectFileURL(appSlug), uploadSlug)
+}
+
+// UploadGenericProjectFile uploads the file to the Generic File Storage of the app.
+func (s *AppService) UploadGenericProjectFile(pth string, params UploadGenericProjectFileParams) error {
+	f, err := os.Open(pth)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Debugf("failed to close generic project file: %s", err)
+		}
+	}()
+
+	i, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	type Params struct {
+		UploadGenericProjectFileParams
+		UploadFileName string `json:"upload_file_name"`
+		UploadFileSize int64  `json:"upload_file_size"`
+	}
+	p := Params{UploadGenericProjectFileParams: params}
+	p.UploadFileSize = i.Size()
+	p.UploadFileName = filepath.Base(f.Name())
+
+	// register file
+	req, err := s.client.newRequest(http.MethodPost, UploadGenericProjectFileURL(s.Slug), p)
+	if err != nil {
+		return err
+	}
+
+	type UploadGenericProjectFileResponse struct {
+		Data struct {
+			UploadURL string `json:"upload_url"`
+			Slug      string `json:"slug"`
+		} `json:"data"`
+	}
+
+	var r UploadGenericProjectFileResponse
+
+	if err := s.client.do(req, &r); err != nil {
+		return err
+	}
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	// upload file
+	req, err = http.NewRequest(http.MethodPut, r.Data.UploadURL, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	if err := s.client.do(req, nil); err != nil {
+		return err
+	}
+
+	// confirm upload
+	req, err = s.client.newRequest(http.MethodPost, UploadGenericProjectFileConfirmURL(s.Slug, r.Data.Slug), nil)
+	if err != nil {
+		return err
+	}
+
+	return s.client.do(req, nil)
+}
@@ -0,0 +1,98 @@
+package bitriseio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Stack OSs
+const (
+	StackOSMacOS = "macos"
+	StackOSLinux = "linux"
+)
+
+// OtherProjectType is the generic project type, its config is used for unknown project types.
+const OtherProjectType = "other"
+
+// ProjectType describes how the apps of a project type are registered and built.
+type ProjectType struct {
+	// DefaultConfig is the static default config selected by RegisterFinish.
+	// Register finish endpoint is prepared for the use case of the bitrise.io website's frontend,
+	// where the user can let the scanner to generate a scan result or use 'manual' config,
+	// in which case the user selects one of our static default configs.
+	// Since in case of local project registration the frontend is not involved, we can use only the 'manual' config
+	// and select any of the give project type's default configs.
+	// Later the tool updated the project's bitrise.yml by calling the '/apps/slug/bitrise.yml' endpoint.
+	DefaultConfig string
+	// StackOS is the OS of the default stack, empty if it depends on the project.
+	StackOS string
+	// RequiredStackOS is set if the project can only be built on stacks of the given OS.
+	RequiredStackOS string
+	// CrossPlatform projects need a macOS stack to build the iOS app.
+	CrossPlatform bool
+	// IOSCodesign and AndroidCodesign are set if the native project is surely present,
+	// so its codesigning files can be exported.
+	IOSCodesign     bool
+	AndroidCodesign bool
+}
+
+// projectTypes is the single source of truth of the supported project types.
+// Project types without a static default config on bitrise.io ("web", "xamarin", "unity", "go") use the generic one.
+var projectTypes = map[string]ProjectType{
+	"android":              {DefaultConfig: "default-android-config", StackOS: StackOSLinux, AndroidCodesign: true},
+	"cordova":              {DefaultConfig: "default-cordova-config", StackOS: StackOSMacOS, CrossPlatform: true},
+	"fastlane":             {DefaultConfig: "default-fastlane-android-config"},
+	"flutter":              {DefaultConfig: "flutter-config-test-android-2", StackOS: StackOSMacOS, CrossPlatform: true},
+	"go":                   {DefaultConfig: "other-config", StackOS: StackOSLinux},
+	"ionic":                {DefaultConfig: "default-ionic-config", StackOS: StackOSMacOS, CrossPlatform: true},
+	"ios":                  {DefaultConfig: "default-ios-config", StackOS: StackOSMacOS, RequiredStackOS: StackOSMacOS, IOSCodesign: true},
+	"java":                 {DefaultConfig: "default-java-gradle-config", StackOS: StackOSLinux},
+	"kotlin-multiplatform": {DefaultConfig: "default-kotlin-multiplatform-config", StackOS: StackOSMacOS, CrossPlatform: true},
+	"macos":                {DefaultConfig: "default-macos-config", StackOS: StackOSMacOS, RequiredStackOS: StackOSMacOS},
+	"node-js":              {DefaultConfig: "default-node-js-npm-config", StackOS: StackOSLinux},
+	"react-native":         {DefaultConfig: "default-react-native-config", StackOS: StackOSMacOS, CrossPlatform: true},
+	"unity":                {DefaultConfig: "other-config", StackOS: StackOSMacOS, CrossPlatform: true},
+	"web":                  {DefaultConfig: "other-config"},
+	"xamarin":              {DefaultConfig: "other-config", StackOS: StackOSMacOS, CrossPlatform: true},
+	OtherProjectType:       {DefaultConfig: "other-config", StackOS: StackOSLinux, IOSCodesign: true, AndroidCodesign: true},
+}
+
+// ProjectTypes returns the supported project types, in alphabetical order.
+func ProjectTypes() []string {
+	var types []string
+	for projectType := range projectTypes {
+		types = append(types, projectType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// IsSupportedProjectType ...
+func IsSupportedProjectType(projectType string) bool {
+	_, ok := LookupProjectType(projectType)
+	return ok
+}
+
+// ValidateProjectType returns an error listing the supported project types if the project type is not supported.
+func ValidateProjectType(projectType string) error {
+	if !IsSupportedProjectType(projectType) {
+		return fmt.Errorf("unsupported project type: %s, supported types: %s", projectType, strings.Join(ProjectTypes(), ", "))
+	}
+	return nil
+}
+
+// LookupProjectType returns the description of the project type and whether it is supported.
+func LookupProjectType(projectType string) (ProjectType, bool) {
+	config, ok := projectTypes[projectType]
+	return config, ok
+}
+
+// GetProjectType returns the description of the project type, unknown project types get the generic one.
+// Use LookupProjectType where the generic one's codesigning properties should not apply to unknown project types.
+func GetProjectType(projectType string) ProjectType {
+	if config, ok := LookupProjectType(projectType); ok {
+		return config
+	}
+	return projectTypes[OtherProjectType]
+}
@@ -0,0 +1,40 @@
+package bitriseio
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// SecretParams ...
+type SecretParams struct {
+	Value                    string `json:"value"`
+	IsProtected              bool   `json:"is_protected"`
+	IsExposedForPullRequests bool   `json:"is_exposed_for_pull_requests"`
+}
+
+// SecretURL ...
+func SecretURL(appSlug, name string) string {
+	return fmt.Sprintf(AppsServiceURL+"%s/secrets/%s", appSlug, url.PathEscape(name))
+}
+
+// SecretsService manages the secrets of an app.
+type SecretsService struct {
+	client  *Client
+	appSlug string
+}
+
+// Secrets ...
+func (s *AppService) Secrets() *SecretsService {
+	return &SecretsService{client: s.client, appSlug: s.Slug}
+}
+
+// Upsert creates the app secret with the given name or updates its value if it already exists.
+func (s *SecretsService) Upsert(name string, params SecretParams) error {
+	req, err := s.client.newRequest(http.MethodPut, SecretURL(s.appSlug, name), params)
+	if err != nil {
+		return err
+	}
+
+	return s.client.do(req, nil)
+}
@@ -14,6 +14,7 @@ type RegisterSSHKeyParams struct {
 	AuthSSHPublicKey                 string `json:"auth_ssh_public_key,omitempty"`
 	IsRegisterKeyIntoProviderService bool   `json:"is_register_key_into_provider_service"`
 	Username                         string
+	HostKeys                         *sshutil.KnownHosts `json:"-"`
 }
 
 // RegisterSSHKeyURL ...
@@ -47,6 +48,7 @@ func (s *AppService) RegisterSSHKey(params RegisterSSHKeyParams, repoURL string)
 			},
 			URL:      repoURL,
 			Username: params.Username,
+			HostKeys: params.HostKeys,
 		}); err != nil {
 			return err
 		}
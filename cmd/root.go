@@ -8,6 +8,9 @@ import (
 
 	"github.com/bitrise-io/bitrise-add-new-project/bitriseio"
 	"github.com/bitrise-io/bitrise-add-new-project/phases"
+	"github.com/bitrise-io/bitrise-add-new-project/sshutil"
+	"github.com/bitrise-io/bitrise-init/output"
+	"github.com/bitrise-io/bitrise/v2/models"
 	"github.com/bitrise-io/go-utils/log"
 	"github.com/spf13/cobra"
 )
@@ -19,6 +22,21 @@ const (
 	cmdFlagKeyVerbose         = "verbose"
 	cmdFlagKeyPersonal        = "personal"
 	cmdFlagKeyIsWebsiteSource = "website"
+	cmdFlagKeyKnownHosts      = "known-hosts"
+	cmdFlagKeyMachineType     = "machine-type"
+	cmdFlagKeyStack           = "stack"
+	cmdFlagKeyYMLSource       = "yml-source"
+	cmdFlagKeyScannerAnswer   = "scanner-answer"
+	cmdFlagKeyScannerAnswers  = "scanner-answers"
+	cmdFlagKeyScanDir         = "scan-dir"
+	cmdFlagKeyScanExclude     = "scan-exclude"
+	cmdFlagKeyScanOutput      = "scan-output"
+	cmdFlagKeyScanOutputFmt   = "scan-output-format"
+	cmdFlagKeyProjectType     = "project-type"
+	cmdFlagKeySecretsFile     = "secrets-file"
+	cmdFlagKeyEnvFile         = "env-file"
+	cmdFlagKeyFollow          = "follow"
+	cmdFlagKeyJSONOutput      = "json-output"
 )
 
 var (
@@ -28,6 +46,21 @@ var (
 	cmdFlagPublic          bool
 	cmdFlagPersonal        bool
 	cmdFlagIsWebsiteSource bool
+	cmdFlagKnownHosts      string
+	cmdFlagMachineType     string
+	cmdFlagStack           string
+	cmdFlagYMLSource       string
+	cmdFlagScannerAnswer   []string
+	cmdFlagScannerAnswers  string
+	cmdFlagScanDir         string
+	cmdFlagScanExclude     []string
+	cmdFlagScanOutput      string
+	cmdFlagScanOutputFmt   string
+	cmdFlagProjectType     string
+	cmdFlagSecretsFile     string
+	cmdFlagEnvFile         string
+	cmdFlagFollow          bool
+	cmdFlagJSONOutput      string
 	rootCmd                = &cobra.Command{
 		Run:   run,
 		Use:   "bitrise-add-new-project",
@@ -37,6 +70,17 @@ var (
 			if cmd.Flag(cmdFlagKeyAPIToken).Value.String() == "" {
 				return errors.New("--api-token not defined")
 			}
+			if cmdFlagYMLSource != phases.BitriseYMLSourceWebsite && cmdFlagYMLSource != phases.BitriseYMLSourceRepository {
+				return fmt.Errorf("invalid --%s: %s, valid options: %s, %s", cmdFlagKeyYMLSource, cmdFlagYMLSource, phases.BitriseYMLSourceRepository, phases.BitriseYMLSourceWebsite)
+			}
+			if cmdFlagProjectType != "" {
+				if err := bitriseio.ValidateProjectType(cmdFlagProjectType); err != nil {
+					return fmt.Errorf("invalid --%s: %s", cmdFlagKeyProjectType, err)
+				}
+			}
+			if format, err := output.ParseFormat(cmdFlagScanOutputFmt); err != nil || format == output.RawFormat {
+				return fmt.Errorf("invalid --%s: %s, valid options: %s, %s", cmdFlagKeyScanOutputFmt, cmdFlagScanOutputFmt, output.JSONFormat, output.YAMLFormat)
+			}
 			return nil
 		},
 	}
@@ -49,11 +93,36 @@ func init() {
 	rootCmd.Flags().BoolVar(&cmdFlagVerbose, cmdFlagKeyVerbose, false, "Enable verbose logging")
 	rootCmd.Flags().BoolVar(&cmdFlagPersonal, cmdFlagKeyPersonal, false, "Assign the project to the owner of the personal access token")
 	rootCmd.Flags().BoolVar(&cmdFlagIsWebsiteSource, cmdFlagKeyIsWebsiteSource, false, "Set this flag if the registration started from the Bitrise.io website")
+	rootCmd.Flags().StringVar(&cmdFlagStack, cmdFlagKeyStack, "", "The ID of the stack to run the builds on, it must be available to the selected account")
+	rootCmd.Flags().StringVar(&cmdFlagMachineType, cmdFlagKeyMachineType, "", "The ID of the machine type to run the builds on (e.g. g2.mac.medium)")
+	rootCmd.Flags().StringVar(&cmdFlagYMLSource, cmdFlagKeyYMLSource, phases.BitriseYMLSourceWebsite, "Where the app reads the bitrise.yml from: website (uploaded to bitrise.io) or repository (the bitrise.yml committed on the default branch)")
+	rootCmd.Flags().StringArrayVar(&cmdFlagScannerAnswer, cmdFlagKeyScannerAnswer, nil, "Answer of a scanner option as KEY=VALUE, the key is the option's env key (e.g. BITRISE_SCHEME) or title, use platform=<platform> if multiple platforms are detected (can be repeated)")
+	rootCmd.Flags().StringVar(&cmdFlagScannerAnswers, cmdFlagKeyScannerAnswers, "", "Path of a YAML or JSON file of scanner option answers (key: value), --scanner-answer values take precedence")
+	rootCmd.Flags().StringVar(&cmdFlagScanDir, cmdFlagKeyScanDir, "", "The directory to scan for projects, relative to the repository root (defaults to the root)")
+	rootCmd.Flags().StringArrayVar(&cmdFlagScanExclude, cmdFlagKeyScanExclude, nil, "Glob pattern of a path (relative to the scanned directory) or a file name to skip during the scan, e.g. node_modules (can be repeated)")
+	rootCmd.Flags().StringVar(&cmdFlagScanOutput, cmdFlagKeyScanOutput, "", "Directory to save the raw scan result (options, configs, warnings, errors and icons) and the selected config to, attach it when reporting a misdetected project")
+	rootCmd.Flags().StringVar(&cmdFlagScanOutputFmt, cmdFlagKeyScanOutputFmt, output.YAMLFormat.String(), "Format of the saved scan result: json or yaml")
+	rootCmd.Flags().StringVar(&cmdFlagProjectType, cmdFlagKeyProjectType, "", "The project type of the app (e.g. ios, android, flutter), overrides the bitrise.yml's project_type")
+	rootCmd.Flags().StringVar(&cmdFlagSecretsFile, cmdFlagKeySecretsFile, "", "Path of a dotenv file (KEY=VALUE lines) of app secrets, values of the secrets referenced by the bitrise.yml are read from it instead of prompting")
+	rootCmd.Flags().StringVar(&cmdFlagEnvFile, cmdFlagKeyEnvFile, "", "Path of a dotenv file (KEY=VALUE lines) of non-secret app env vars, merged into the app envs of the bitrise.yml or created as secrets on bitrise.io")
+	rootCmd.Flags().BoolVar(&cmdFlagFollow, cmdFlagKeyFollow, false, "Wait for the first build to finish, streaming its log, the exit code is non-zero if the build fails or can not be followed (pipelines are not supported)")
+	rootCmd.Flags().StringVar(&cmdFlagJSONOutput, cmdFlagKeyJSONOutput, "", "Path of a JSON file to write the registered app, its first build, the build trigger token, the incoming webhook URL and a curl command starting builds to")
+	rootCmd.Flags().StringVar(&cmdFlagKnownHosts, cmdFlagKeyKnownHosts, "", "Path of the known_hosts file to verify SSH host keys against, unknown hosts are rejected instead of asking for confirmation")
 }
 
 func executePhases(cmd cobra.Command) (phases.Progress, error) {
 	progress := phases.Progress{}
 
+	scannerAnswers, err := phases.ParseScannerAnswers(cmdFlagScannerAnswer, cmdFlagScannerAnswers)
+	if err != nil {
+		return phases.Progress{}, err
+	}
+
+	scanOutputFormat, err := output.ParseFormat(cmdFlagScanOutputFmt)
+	if err != nil {
+		return phases.Progress{}, err
+	}
+
 	account, err := phases.Account(cmdFlagAPIToken, cmdFlagPersonal, cmdFlagOrganisation)
 	if err != nil {
 		return phases.Progress{}, err
@@ -92,43 +161,136 @@ func executePhases(cmd cobra.Command) (phases.Progress, error) {
 
 	// ssh key
 	if repoURL.Scheme == phases.SSH {
-		SSHKeys, register, err := phases.PrivateKey(progress.RepoDetails)
+		knownHostsPath, interactive := cmdFlagKnownHosts, cmdFlagKnownHosts == ""
+		if interactive {
+			if knownHostsPath, err = sshutil.DefaultKnownHostsPath(); err != nil {
+				return phases.Progress{}, fmt.Errorf("failed to get known_hosts path, error: %s", err)
+			}
+		}
+		knownHosts, err := sshutil.NewKnownHosts(knownHostsPath, interactive)
+		if err != nil {
+			return phases.Progress{}, err
+		}
+		progress.KnownHosts = knownHosts
+
+		SSHKeys, register, err := phases.PrivateKey(progress.RepoDetails, currentDir, knownHosts)
 		if err != nil {
 			return phases.Progress{}, err
 		}
 		progress.SSHKeys = SSHKeys
 		progress.RegisterSSHKey = register
+
+		uploadKnownHosts, err := phases.UploadKnownHosts(knownHosts)
+		if err != nil {
+			return phases.Progress{}, err
+		}
+		progress.UploadKnownHosts = uploadKnownHosts
 	}
 
 	// bitrise.yml
-	bitriseYML, primaryWorkflow, branch, err := phases.BitriseYML(currentDir, progress.RegisterSSHKey)
+	storedInRepository := cmdFlagYMLSource == phases.BitriseYMLSourceRepository
+	var (
+		bitriseYML        models.BitriseDataModel
+		bitriseYMLContent []byte
+		buildTarget       phases.BuildTarget
+		branch            string
+		detectedPlatforms []string
+	)
+	if storedInRepository {
+		bitriseYML, bitriseYMLContent, buildTarget, branch, err = phases.RepositoryBitriseYML(currentDir)
+	} else {
+		bitriseYML, bitriseYMLContent, buildTarget, branch, detectedPlatforms, err = phases.BitriseYML(currentDir, progress.RegisterSSHKey, phases.ScannerOptions{
+			Answers:      scannerAnswers,
+			Dir:          cmdFlagScanDir,
+			Exclude:      cmdFlagScanExclude,
+			OutputDir:    cmdFlagScanOutput,
+			OutputFormat: scanOutputFormat,
+		})
+	}
 	if err != nil {
 		return phases.Progress{}, err
 	}
-	projectType := bitriseYML.ProjectType
-	if projectType == "" {
-		projectType = "other"
-		bitriseYML.ProjectType = "other"
+	progress.BitriseYMLSource = cmdFlagYMLSource
+
+	// project type
+	projectType, err := phases.ProjectType(bitriseYML.ProjectType, detectedPlatforms, cmdFlagProjectType)
+	if err != nil {
+		return phases.Progress{}, err
 	}
+	bitriseYML.ProjectType = projectType
 	progress.BitriseYML = bitriseYML
-	progress.PrimaryWorkflow = primaryWorkflow
+	progress.BitriseYMLContent = bitriseYMLContent
+	progress.BuildTarget = buildTarget
 	progress.Branch = branch
 	progress.ProjectType = projectType
 
 	log.Debugf("project type\nprogress: %s, yml; %s", projectType, bitriseYML.ProjectType)
 
 	// stack
-	stack, err := phases.Stack(progress.OrganizationSlug, cmdFlagAPIToken, projectType)
+	stack, err := phases.Stack(progress.OrganizationSlug, cmdFlagAPIToken, projectType, cmdFlagStack, phases.BuildTargetStepIDs(bitriseYML, buildTarget))
 	if err != nil {
 		return phases.Progress{}, err
 	}
 	progress.Stack = stack
-	progress.BitriseYML.Meta = map[string]interface{}{
-		"bitrise.io": map[string]string{
-			"stack": stack,
-		},
+	if storedInRepository {
+		if declared := phases.BitriseIOMeta(progress.BitriseYML, "stack"); declared != "" && declared != stack {
+			log.Warnf("The bitrise.yml in the repository declares the %s stack, it takes precedence over the selected stack: %s", declared, stack)
+		}
+	} else {
+		phases.SetStack(&progress.BitriseYML, stack)
+	}
+
+	// machine type
+	machineType, err := phases.MachineType(progress.OrganizationSlug, cmdFlagAPIToken, stack, cmdFlagMachineType)
+	if err != nil {
+		return phases.Progress{}, err
+	}
+	progress.MachineType = machineType
+	if machineType != "" && !storedInRepository {
+		phases.SetBitriseIOMeta(&progress.BitriseYML, "machine_type_id", machineType)
 	}
 
+	// app env vars
+	envVarsBitriseYML, envVarsBitriseYMLContent, envVarSecrets, err := phases.AppEnvVars(progress.BitriseYML, progress.BitriseYMLContent, cmdFlagEnvFile, storedInRepository)
+	if err != nil {
+		return phases.Progress{}, err
+	}
+	progress.BitriseYML = envVarsBitriseYML
+	progress.BitriseYMLContent = envVarsBitriseYMLContent
+
+	// the bitrise.yml stored in the repository is not uploaded, it can only be changed by pushing a commit
+	if !storedInRepository {
+		// triggers
+		triggeredBitriseYML, triggeredBitriseYMLContent, err := phases.Triggers(progress.BitriseYML, progress.BitriseYMLContent, progress.BuildTarget, progress.Branch)
+		if err != nil {
+			return phases.Progress{}, err
+		}
+		progress.BitriseYML = triggeredBitriseYML
+		progress.BitriseYMLContent = triggeredBitriseYMLContent
+
+		// review bitrise.yml
+		reviewedBitriseYML, reviewedBitriseYMLContent, err := phases.PreviewBitriseYML(progress)
+		if err != nil {
+			return phases.Progress{}, err
+		}
+		progress.BitriseYML = reviewedBitriseYML
+		progress.BitriseYMLContent = reviewedBitriseYMLContent
+
+		// the generated bitrise.yml is only stored on bitrise.io unless saved into the repository
+		if bitriseYMLContent == nil {
+			if err := phases.SaveBitriseYML(currentDir, progress.BitriseYMLContent); err != nil {
+				return phases.Progress{}, err
+			}
+		}
+	}
+
+	// secrets
+	secrets, err := phases.Secrets(progress.BitriseYML, projectType, cmdFlagSecretsFile, envVarSecrets)
+	if err != nil {
+		return phases.Progress{}, err
+	}
+	progress.Secrets = append(envVarSecrets, secrets...)
+
 	// webhook
 	wh, err := phases.AddWebhook()
 	if err != nil {
@@ -143,6 +305,13 @@ func executePhases(cmd cobra.Command) (phases.Progress, error) {
 	}
 	progress.Codesign = codesign
 
+	// generic files
+	genericFiles, err := phases.GenericFiles(currentDir)
+	if err != nil {
+		return phases.Progress{}, err
+	}
+	progress.GenericFiles = genericFiles
+
 	return progress, nil
 }
 
@@ -160,10 +329,29 @@ func run(cmd *cobra.Command, args []string) {
 		source = bitriseio.SourceBanpWebsite
 	}
 
-	if err := phases.Register(cmdFlagAPIToken, source, progress, os.Stdin); err != nil {
+	result, err := phases.Register(cmdFlagAPIToken, source, progress, os.Stdin)
+	if err != nil {
 		fmt.Println("failed to add Bitrise app, error:", err)
 		os.Exit(1)
 	}
+
+	if cmdFlagJSONOutput != "" {
+		if err := phases.WriteRegisterResult(cmdFlagJSONOutput, *result); err != nil {
+			fmt.Println("failed to write JSON output, error:", err)
+			os.Exit(1)
+		}
+	}
+
+	if cmdFlagFollow {
+		succeeded, err := phases.FollowBuild(cmdFlagAPIToken, result.AppSlug, result.Build)
+		if err != nil {
+			fmt.Println("failed to follow the first build, error:", err)
+			os.Exit(1)
+		}
+		if !succeeded {
+			os.Exit(1)
+		}
+	}
 }
 
 // Execute ...
@@ -0,0 +1,218 @@
+package phases
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/bitrise-add-new-project/sshutil"
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/manifoldco/promptui"
+)
+
+const gitModulesName = ".gitmodules"
+
+// resolveRelativeRepoURL resolves a submodule URL relative to the superproject's clone URL,
+// the same way git does (e.g. ../other.git next to the superproject).
+func resolveRelativeRepoURL(baseURL, relativeURL string) (string, error) {
+	base, err := parseURL(baseURL)
+	if err != nil {
+		return "", err
+	}
+	base.Path = strings.TrimSuffix(base.Path, urlPathSeperator) + urlPathSeperator
+
+	ref, err := url.Parse(relativeURL)
+	if err != nil {
+		return "", err
+	}
+
+	return base.ResolveReference(ref).String(), nil
+}
+
+// submoduleURLs returns the clone URLs of the submodules declared in the .gitmodules file
+// of the repository, relative URLs are resolved against the repository URL.
+func submoduleURLs(searchDir, repoURL string) ([]string, error) {
+	pth := filepath.Join(searchDir, gitModulesName)
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s, error: %s", pth, err)
+	}
+
+	modules := config.NewModules()
+	if err := modules.Unmarshal(content); err != nil {
+		return nil, fmt.Errorf("failed to parse %s, error: %s", pth, err)
+	}
+
+	var names []string
+	for name := range modules.Submodules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var urls []string
+	for _, name := range names {
+		submoduleURL := modules.Submodules[name].URL
+		if submoduleURL == "" {
+			continue
+		}
+
+		if strings.HasPrefix(submoduleURL, "./") || strings.HasPrefix(submoduleURL, "../") {
+			resolved, err := resolveRelativeRepoURL(repoURL, submoduleURL)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve URL (%s) of submodule %s, error: %s", submoduleURL, name, err)
+			}
+			submoduleURL = resolved
+		}
+
+		urls = append(urls, submoduleURL)
+	}
+
+	return urls, nil
+}
+
+// sshRepoURLs filters the given repository URLs to the ones accessed over SSH,
+// only these can be validated with the generated SSH key.
+func sshRepoURLs(repoURLs []string) []string {
+	var sshURLs []string
+	for _, repoURL := range repoURLs {
+		parsed, err := parseURL(repoURL)
+		if err != nil {
+			log.Warnf("Invalid repository URL (%s), error: %s", repoURL, err)
+			continue
+		}
+		if parsed.Scheme != "ssh" {
+			log.Debugf("Skipping non SSH repository: %s", repoURL)
+			continue
+		}
+		sshURLs = append(sshURLs, repoURL)
+	}
+	return sshURLs
+}
+
+func askAdditionalRepoURLs() ([]string, error) {
+	var repoURLs []string
+	for {
+		prompt := promptui.Prompt{
+			Label: "Enter the SSH URL of an additional private repository (leave empty to continue)",
+		}
+
+		repoURL, err := prompt.Run()
+		if err != nil {
+			return nil, fmt.Errorf("scan user input: %s", err)
+		}
+
+		repoURL = strings.TrimSpace(repoURL)
+		if repoURL == "" {
+			return repoURLs, nil
+		}
+
+		if len(sshRepoURLs([]string{repoURL})) == 0 {
+			log.Warnf("Only SSH repository URLs can be verified (e.g. git@github.com:owner/repo.git)")
+			continue
+		}
+
+		repoURLs = append(repoURLs, repoURL)
+	}
+}
+
+// additionalRepoURLs collects the SSH URLs of the submodules and the additional repositories entered by the user.
+func additionalRepoURLs(searchDir string, repoURL string) ([]string, error) {
+	submodules, err := submoduleURLs(searchDir, repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	repoURLs := sshRepoURLs(submodules)
+	if len(repoURLs) > 0 {
+		log.Printf("Submodules found in %s:", gitModulesName)
+		for _, submoduleURL := range repoURLs {
+			log.Printf("- %s", submoduleURL)
+		}
+	}
+
+	additional, err := askAdditionalRepoURLs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, additionalURL := range additional {
+		isDuplicate := false
+		for _, existing := range repoURLs {
+			if existing == additionalURL {
+				isDuplicate = true
+				break
+			}
+		}
+		if !isDuplicate {
+			repoURLs = append(repoURLs, additionalURL)
+		}
+	}
+
+	return repoURLs, nil
+}
+
+func validateRepoAccess(privateKey []byte, repoURLs []string, hostKeys *sshutil.KnownHosts) []string {
+	var failed []string
+	for _, repoURL := range repoURLs {
+		username := "git"
+		if parsed, err := parseURL(repoURL); err == nil && parsed.User.Username() != "" {
+			username = parsed.User.Username()
+		}
+
+		if valid, err := sshutil.ValidatePrivateKey(privateKey, username, repoURL, hostKeys); !valid {
+			log.Errorf("- %s: %s", repoURL, err)
+			failed = append(failed, repoURL)
+			continue
+		}
+		log.Printf("- %s: %s", repoURL, colorstring.Green("OK"))
+	}
+	return failed
+}
+
+// verifyAdditionalRepoAccess checks that the SSH key can access each of the given repositories,
+// until all of them pass or the user explicitly skips the verification.
+func verifyAdditionalRepoAccess(keys sshutil.SSHKeyPair, repoURLs []string, hostKeys *sshutil.KnownHosts) error {
+	const (
+		optionRetry = "Retry"
+		optionSkip  = "Skip verification"
+	)
+
+	for {
+		log.Printf("Checking access to the additional repositories:")
+		failed := validateRepoAccess(keys.PrivateKey, repoURLs, hostKeys)
+		if len(failed) == 0 {
+			return nil
+		}
+
+		log.Warnf("Could not access %d of %d repositories with the SSH key.", len(failed), len(repoURLs))
+
+		prompt := promptui.Select{
+			Label: "Add the SSH public key to the failing repositories, then retry",
+			Items: []string{optionRetry, optionSkip},
+			Templates: &promptui.SelectTemplates{
+				Label:    fmt.Sprintf("%s {{.}} ", promptui.IconInitial),
+				Selected: "Additional repository access: {{ . | green }}",
+			},
+		}
+
+		_, answer, err := prompt.Run()
+		if err != nil {
+			return fmt.Errorf("scan user input: %s", err)
+		}
+
+		if answer == optionSkip {
+			log.Warnf("Skipping verification, builds may fail to access: %s", strings.Join(failed, ", "))
+			return nil
+		}
+
+		repoURLs = failed
+	}
+}
@@ -0,0 +1,68 @@
+package phases
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resolveRelativeRepoURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseURL     string
+		relativeURL string
+		want        string
+	}{
+		{
+			name:        "sibling repository",
+			baseURL:     "ssh://git@github.com/bitrise-io/go-utils.git",
+			relativeURL: "../go-steputils.git",
+			want:        "ssh://git@github.com/bitrise-io/go-steputils.git",
+		},
+		{
+			name:        "sibling repository of scp-like URL",
+			baseURL:     "git@github.com:bitrise-io/go-utils.git",
+			relativeURL: "../go-steputils.git",
+			want:        "ssh://git@github.com/bitrise-io/go-steputils.git",
+		},
+		{
+			name:        "nested repository",
+			baseURL:     "ssh://git@github.com/bitrise-io/go-utils.git",
+			relativeURL: "./sub.git",
+			want:        "ssh://git@github.com/bitrise-io/go-utils.git/sub.git",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveRelativeRepoURL(tt.baseURL, tt.relativeURL)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_submoduleURLs(t *testing.T) {
+	dir := t.TempDir()
+
+	urls, err := submoduleURLs(dir, "git@github.com:bitrise-io/go-utils.git")
+	require.NoError(t, err)
+	require.Empty(t, urls)
+
+	gitModules := `[submodule "b"]
+	path = b
+	url = ../b.git
+[submodule "a"]
+	path = a
+	url = git@bitbucket.org:bitrise-io/a.git
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, gitModulesName), []byte(gitModules), 0600))
+
+	urls, err = submoduleURLs(dir, "git@github.com:bitrise-io/go-utils.git")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"git@bitbucket.org:bitrise-io/a.git",
+		"ssh://git@github.com/bitrise-io/b.git",
+	}, urls)
+}
@@ -0,0 +1,328 @@
+package phases
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/bitrise-add-new-project/bitriseio"
+	"github.com/bitrise-io/bitrise/v2/models"
+	envmanModels "github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/manifoldco/promptui"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	appKey  = "app"
+	envsKey = "envs"
+)
+
+const (
+	envVarsMerge   = "Merge into the app envs of the bitrise.yml"
+	envVarsSecrets = "Create as secrets on bitrise.io (exposed to pull request builds)"
+)
+
+// appEnvValue returns the value of the app env var and whether the bitrise.yml defines it.
+func appEnvValue(bitriseYML models.BitriseDataModel, key string) (string, bool) {
+	for _, env := range bitriseYML.App.Environments {
+		if k, value, err := env.GetKeyValuePair(); err == nil && k == key {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// mergeAppEnvVars sets the app envs of the bitrise.yml model, existing envs keep their position and options, new ones are appended.
+func mergeAppEnvVars(bitriseYML *models.BitriseDataModel, envVars []envVar) {
+	for _, envVar := range envVars {
+		replaced := false
+		for _, env := range bitriseYML.App.Environments {
+			if key, _, err := env.GetKeyValuePair(); err == nil && key == envVar.Key {
+				env[key] = envVar.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			bitriseYML.App.Environments = append(bitriseYML.App.Environments, envmanModels.EnvironmentItemModel{envVar.Key: envVar.Value})
+		}
+	}
+}
+
+// envItemKeyValue returns the key and value nodes of the env item (e.g. "- KEY: value"), the opts are skipped.
+func envItemKeyValue(item *yaml.Node) (*yaml.Node, *yaml.Node) {
+	if item.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(item.Content); i += 2 {
+		if item.Content[i].Value != "opts" {
+			return item.Content[i], item.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// mergeAppEnvsFallback merges the env vars by re-encoding the YAML node tree,
+// used when existing envs are replaced or the app envs can not be patched line by line (e.g. flow style).
+func mergeAppEnvsFallback(doc *yaml.Node, envVars []envVar) ([]byte, error) {
+	parent := doc.Content[0]
+	_, app := mappingKeyValue(parent, appKey)
+	if app == nil {
+		app = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: appKey}, app)
+	} else if app.Kind != yaml.MappingNode {
+		*app = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+
+	_, envs := mappingKeyValue(app, envsKey)
+	if envs == nil {
+		envs = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		app.Content = append(app.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: envsKey}, envs)
+	} else if envs.Kind != yaml.SequenceNode {
+		*envs = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	}
+
+	for _, envVar := range envVars {
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: envVar.Value}
+
+		replaced := false
+		for _, item := range envs.Content {
+			if keyNode, valueNode := envItemKeyValue(item); keyNode != nil && keyNode.Value == envVar.Key {
+				*valueNode = *value
+				replaced = true
+			}
+		}
+		if !replaced {
+			envs.Content = append(envs.Content, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: envVar.Key},
+				value,
+			}})
+		}
+	}
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// mergeAppEnvs merges the env vars into the app envs of the given bitrise.yml content.
+// New envs are appended after the existing ones, the same way as in the model, the rest of the file is kept as is.
+func mergeAppEnvs(content []byte, envVars []envVar) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse bitrise.yml: %s", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("bitrise.yml root is not a mapping")
+	}
+	root := doc.Content[0]
+	if !isBlockMapping(root) {
+		return mergeAppEnvsFallback(&doc, envVars)
+	}
+
+	var entries []string
+	for _, envVar := range envVars {
+		entries = append(entries, fmt.Sprintf("- %s: %s", envVar.Key, yamlScalar(envVar.Value)))
+	}
+	indented := func(indent string) []string {
+		var lines []string
+		for _, entry := range entries {
+			lines = append(lines, indent+entry)
+		}
+		return lines
+	}
+
+	lines := newBitriseYMLLines(content)
+
+	appKeyNode, app := mappingKeyValue(root, appKey)
+	if app == nil {
+		indent := ""
+		if len(root.Content) > 0 {
+			indent = strings.Repeat(" ", root.Content[0].Column-1)
+		}
+		lines.insertAfter(len(lines.lines), append([]string{indent + appKey + ":", indent + "  " + envsKey + ":"}, indented(indent+"  ")...)...)
+		return lines.bytes(), nil
+	}
+	if !isBlockMapping(app) && !isEmpty(app) {
+		return mergeAppEnvsFallback(&doc, envVars)
+	}
+
+	envsKeyNode, envs := mappingKeyValue(app, envsKey)
+	if envs == nil {
+		indent := childIndent(appKeyNode, app)
+		lines.insertAfter(appKeyNode.Line, append([]string{indent + envsKey + ":"}, indented(indent)...)...)
+		return lines.bytes(), nil
+	}
+	if isEmpty(envs) {
+		lines.insertAfter(envsKeyNode.Line, indented(strings.Repeat(" ", envsKeyNode.Column-1))...)
+		return lines.bytes(), nil
+	}
+	if envs.Kind != yaml.SequenceNode || envs.Style&yaml.FlowStyle != 0 || len(envs.Content) == 0 {
+		return mergeAppEnvsFallback(&doc, envVars)
+	}
+	for _, item := range envs.Content {
+		keyNode, valueNode := envItemKeyValue(item)
+		if keyNode == nil || keyNode.Line != item.Line {
+			return mergeAppEnvsFallback(&doc, envVars)
+		}
+		for _, envVar := range envVars {
+			if keyNode.Value == envVar.Key {
+				return mergeAppEnvsFallback(&doc, envVars)
+			}
+		}
+		// the end of a block scalar can not be told apart from the blank lines after it
+		if valueNode.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			return mergeAppEnvsFallback(&doc, envVars)
+		}
+	}
+
+	// The new items are appended after the last one, before the next key of the app or of the root.
+	end := nextKeyLine(app, envsKeyNode)
+	if end == 0 {
+		end = nextKeyLine(root, appKeyNode)
+	}
+	if end == 0 {
+		end = len(lines.lines) + 1
+	}
+	last := envs.Content[len(envs.Content)-1]
+	for end-1 > last.Line && isBlankOrComment(lines.lines[end-2]) {
+		end--
+	}
+
+	// The new items get the indentation of the first item's dash.
+	firstLine := lines.lines[envs.Content[0].Line-1]
+	indent := firstLine[:len(firstLine)-len(strings.TrimLeft(firstLine, " "))]
+	lines.insertAfter(end-1, indented(indent)...)
+	return lines.bytes(), nil
+}
+
+// nextKeyLine returns the line of the key following the given key in the mapping, 0 if it is the last one.
+func nextKeyLine(mapping, keyNode *yaml.Node) int {
+	for i := 0; i+2 < len(mapping.Content); i += 2 {
+		if mapping.Content[i] == keyNode {
+			return mapping.Content[i+2].Line
+		}
+	}
+	return 0
+}
+
+func isBlankOrComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
+
+func selectEnvVarsTarget() (string, error) {
+	prompt := promptui.Select{
+		Label: "How do you want to set the env vars?",
+		Items: []string{envVarsMerge, envVarsSecrets},
+		Templates: &promptui.SelectTemplates{
+			Label:    fmt.Sprintf("%s {{.}} ", promptui.IconInitial),
+			Selected: "Env vars: {{ . | green }}",
+		},
+	}
+
+	_, target, err := prompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("scan user input: %s", err)
+	}
+	return target, nil
+}
+
+// envVarsToSecrets returns the secrets to create for the env vars on bitrise.io.
+// The API has no endpoint for app env vars, so they are created as unprotected secrets exposed to pull request builds,
+// which the builds read the same way as the app envs of the bitrise.yml.
+func envVarsToSecrets(envVars []envVar) []Secret {
+	var secrets []Secret
+	for _, envVar := range envVars {
+		secrets = append(secrets, Secret{
+			Name: envVar.Key,
+			SecretParams: bitriseio.SecretParams{
+				Value:                    envVar.Value,
+				IsExposedForPullRequests: true,
+			},
+		})
+	}
+	return secrets
+}
+
+// AppEnvVars sets the app env vars of the dotenv file, either by merging them into the app envs of the uploaded bitrise.yml
+// or by creating them as secrets on bitrise.io. The bitrise.yml stored in the repository is not uploaded, so its env vars are always secrets.
+// The app envs of the bitrise.yml override the secrets: when creating secrets, the env vars already in app.envs are updated
+// in the uploaded bitrise.yml instead, or skipped if the bitrise.yml is stored in the repository.
+func AppEnvVars(bitriseYML models.BitriseDataModel, content []byte, envFile string, storedInRepository bool) (models.BitriseDataModel, []byte, []Secret, error) {
+	if envFile == "" {
+		return bitriseYML, content, nil, nil
+	}
+
+	envVars, err := readDotenv(envFile)
+	if err != nil {
+		return models.BitriseDataModel{}, nil, nil, err
+	}
+	if len(envVars) == 0 {
+		log.Warnf("No env vars found in %s", envFile)
+		return bitriseYML, content, nil, nil
+	}
+
+	fmt.Println()
+	log.Infof("APP ENV VARS")
+	for _, envVar := range envVars {
+		if value, ok := appEnvValue(bitriseYML, envVar.Key); ok {
+			log.Printf("- %s: %s (already in app.envs, current value: %s)", envVar.Key, envVar.Value, colorstring.Yellow(value))
+		} else {
+			log.Printf("- %s: %s", envVar.Key, envVar.Value)
+		}
+	}
+
+	target := envVarsSecrets
+	if storedInRepository {
+		log.Printf("The bitrise.yml is read from the repository, the env vars are created as secrets on bitrise.io.")
+	} else if target, err = selectEnvVarsTarget(); err != nil {
+		return models.BitriseDataModel{}, nil, nil, err
+	}
+
+	if target == envVarsMerge {
+		if content, err = mergeEnvVarsIntoBitriseYML(&bitriseYML, content, envVars); err != nil {
+			return models.BitriseDataModel{}, nil, nil, err
+		}
+		return bitriseYML, content, nil, nil
+	}
+
+	var secretEnvVars, appEnvVars []envVar
+	for _, envVar := range envVars {
+		if _, ok := appEnvValue(bitriseYML, envVar.Key); !ok {
+			secretEnvVars = append(secretEnvVars, envVar)
+		} else if storedInRepository {
+			log.Warnf("%s is skipped: the app envs of the bitrise.yml override the secret, update it in the repository instead.", envVar.Key)
+		} else {
+			log.Printf("%s is already in app.envs, its value is updated in the bitrise.yml, as the app envs override the secrets.", envVar.Key)
+			appEnvVars = append(appEnvVars, envVar)
+		}
+	}
+	if len(appEnvVars) > 0 {
+		if content, err = mergeEnvVarsIntoBitriseYML(&bitriseYML, content, appEnvVars); err != nil {
+			return models.BitriseDataModel{}, nil, nil, err
+		}
+	}
+
+	return bitriseYML, content, envVarsToSecrets(secretEnvVars), nil
+}
+
+// mergeEnvVarsIntoBitriseYML merges the env vars into the app envs of both the bitrise.yml model and its content.
+func mergeEnvVarsIntoBitriseYML(bitriseYML *models.BitriseDataModel, content []byte, envVars []envVar) ([]byte, error) {
+	if content != nil {
+		var err error
+		if content, err = mergeAppEnvs(content, envVars); err != nil {
+			return nil, fmt.Errorf("failed to merge env vars into bitrise.yml, error: %s", err)
+		}
+	}
+	mergeAppEnvVars(bitriseYML, envVars)
+	return content, nil
+}
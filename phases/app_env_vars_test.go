@@ -0,0 +1,177 @@
+package phases
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_mergeAppEnvs(t *testing.T) {
+	envVars := []envVar{{Key: "API_URL", Value: "https://example.com"}, {Key: "DEBUG", Value: "true"}}
+
+	tests := []struct {
+		name    string
+		content string
+		envVars []envVar
+		want    string
+	}{
+		{
+			name: "no app section",
+			content: `format_version: "13"
+workflows:
+  primary: {}
+`,
+			envVars: envVars,
+			want: `format_version: "13"
+workflows:
+  primary: {}
+app:
+  envs:
+  - API_URL: https://example.com
+  - DEBUG: "true"
+`,
+		},
+		{
+			name: "no envs",
+			content: `format_version: "13"
+app:
+    title: App
+workflows:
+  primary: {}
+`,
+			envVars: envVars,
+			want: `format_version: "13"
+app:
+    envs:
+    - API_URL: https://example.com
+    - DEBUG: "true"
+    title: App
+workflows:
+  primary: {}
+`,
+		},
+		{
+			name: "existing envs",
+			content: `format_version: "13"
+app:
+  envs:
+    # project
+    - BITRISE_PROJECT_PATH: App.xcodeproj
+      opts:
+        is_expand: false
+
+# workflows
+workflows:
+  primary: {}
+`,
+			envVars: envVars,
+			want: `format_version: "13"
+app:
+  envs:
+    # project
+    - BITRISE_PROJECT_PATH: App.xcodeproj
+      opts:
+        is_expand: false
+    - API_URL: https://example.com
+    - DEBUG: "true"
+
+# workflows
+workflows:
+  primary: {}
+`,
+		},
+		{
+			name: "existing envs at the end",
+			content: `format_version: "13"
+app:
+  title: App
+  envs:
+  - BITRISE_PROJECT_PATH: App.xcodeproj
+`,
+			envVars: envVars,
+			want: `format_version: "13"
+app:
+  title: App
+  envs:
+  - BITRISE_PROJECT_PATH: App.xcodeproj
+  - API_URL: https://example.com
+  - DEBUG: "true"
+`,
+		},
+		{
+			name: "existing key is replaced",
+			content: `format_version: "13"
+app:
+  envs:
+  - DEBUG: "false"
+    opts:
+      is_expand: false
+  - BITRISE_PROJECT_PATH: App.xcodeproj # project
+`,
+			envVars: envVars,
+			want: `format_version: "13"
+app:
+  envs:
+    - DEBUG: "true"
+      opts:
+        is_expand: false
+    - BITRISE_PROJECT_PATH: App.xcodeproj # project
+    - API_URL: https://example.com
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeAppEnvs([]byte(tt.content), tt.envVars)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func Test_mergeAppEnvVars(t *testing.T) {
+	const config = `format_version: "13"
+app:
+  envs:
+  - DEBUG: "false"
+    opts:
+      is_expand: false
+`
+	var bitriseYML models.BitriseDataModel
+	require.NoError(t, yaml.Unmarshal([]byte(config), &bitriseYML))
+
+	mergeAppEnvVars(&bitriseYML, []envVar{{Key: "DEBUG", Value: "true"}, {Key: "API_URL", Value: "https://example.com"}})
+
+	value, ok := appEnvValue(bitriseYML, "DEBUG")
+	require.True(t, ok)
+	require.Equal(t, "true", value)
+	require.Contains(t, bitriseYML.App.Environments[0], "opts")
+
+	value, ok = appEnvValue(bitriseYML, "API_URL")
+	require.True(t, ok)
+	require.Equal(t, "https://example.com", value)
+	require.Len(t, bitriseYML.App.Environments, 2)
+}
+
+func TestAppEnvVars_storedInRepository(t *testing.T) {
+	const config = `format_version: "13"
+app:
+  envs:
+  - DEBUG: "false"
+`
+	var bitriseYML models.BitriseDataModel
+	require.NoError(t, yaml.Unmarshal([]byte(config), &bitriseYML))
+
+	envFile := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(envFile, []byte("DEBUG=true\nAPI_URL=https://example.com\n"), 0600))
+
+	_, _, secrets, err := AppEnvVars(bitriseYML, nil, envFile, true)
+	require.NoError(t, err)
+	require.Len(t, secrets, 1)
+	require.Equal(t, "API_URL", secrets[0].Name)
+	require.True(t, secrets[0].IsExposedForPullRequests)
+}
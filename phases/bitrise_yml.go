@@ -2,12 +2,16 @@ package phases
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	scannerModels "github.com/bitrise-io/bitrise-init/models"
+	"github.com/bitrise-io/bitrise-init/output"
 	"github.com/bitrise-io/bitrise-init/scanner"
 	"github.com/bitrise-io/bitrise/v2/bitrise"
 	"github.com/bitrise-io/bitrise/v2/models"
@@ -159,28 +163,35 @@ func ParseBitriseYMLFile(inputReader io.Reader) (models.BitriseDataModel, []stri
 	return decodedBitriseYML, warnings, nil
 }
 
-func selectBitriseYMLFile(inputReader io.Reader, potentialBitriseYMLFilePath string) (models.BitriseDataModel, error) {
+// selectBitriseYMLFile returns the selected bitrise.yml's data model and its original content,
+// the latter is uploaded to keep the comments and formatting of the file.
+func selectBitriseYMLFile(inputReader io.Reader, potentialBitriseYMLFilePath string) (models.BitriseDataModel, []byte, error) {
 	for {
 		filePath, err := askBitriseYMLFile(potentialBitriseYMLFilePath)
 		if err != nil {
-			return models.BitriseDataModel{}, fmt.Errorf("prompt user: %s", err)
+			return models.BitriseDataModel{}, nil, fmt.Errorf("prompt user: %s", err)
 		}
 
-		bitriseYMLFile, err := os.Open(filePath)
-		defer func() {
-			if err := bitriseYMLFile.Close(); err != nil {
-				log.Warnf("failed to close file, error: %s", err)
-			}
-		}()
+		content, err := os.ReadFile(filePath)
 		if err != nil {
 			if !os.IsNotExist(err) {
-				return models.BitriseDataModel{}, fmt.Errorf("failed to open file (%s), error: %s", filePath, err)
+				return models.BitriseDataModel{}, nil, fmt.Errorf("failed to open file (%s), error: %s", filePath, err)
 			}
 			log.Warnf("File (%s) does not exist.", filePath)
 			continue
 		}
 
-		decodedBitriseYML, warnings, err := ParseBitriseYMLFile(bitriseYMLFile)
+		if isModularBitriseYML(content) {
+			merged, err := mergeLocalBitriseYML(filePath)
+			if err != nil {
+				log.Warnf("Failed to resolve the included config modules, error: %s", err)
+				continue
+			}
+			log.Printf("bitrise.io stores a single bitrise.yml, the merged configuration will be uploaded.")
+			content = merged
+		}
+
+		decodedBitriseYML, warnings, err := ParseBitriseYMLFile(bytes.NewReader(content))
 		if err != nil {
 			log.Warnf("Failed to parse bitrise.yml, error: %s", err)
 			continue
@@ -190,50 +201,82 @@ func selectBitriseYMLFile(inputReader io.Reader, potentialBitriseYMLFilePath str
 				log.Warnf(warning)
 			}
 		}
-		return decodedBitriseYML, nil
+
+		lint := LintBitriseYML(decodedBitriseYML, decodedBitriseYML.ProjectType)
+		if len(lint.Errors) > 0 {
+			log.Errorf("The bitrise.yml has errors, fix them and select the file again:")
+			logLintResult(lint)
+			continue
+		} else if len(lint.Warnings) > 0 {
+			log.Warnf("The bitrise.yml has warnings:")
+			logLintResult(lint)
+		}
+		return decodedBitriseYML, content, nil
 	}
 }
 
-func selectWorkflow(buildBitriseYML models.BitriseDataModel, inputReader io.Reader) (string, error) {
-	if len(buildBitriseYML.Workflows) == 0 {
-		return "", fmt.Errorf("no workflows found in bitrise.yml")
+// scannerBranch returns the tracking branch of the current branch without prompting, used when the scanner answers are given.
+func scannerBranch(searchDir string) (string, error) {
+	branch, err := currentBranch(searchDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current branch, error: %s", err)
 	}
-
-	const defaultWorkflowName = "primary"
-	if _, contains := buildBitriseYML.Workflows[defaultWorkflowName]; contains {
-		return defaultWorkflowName, nil
+	if branch.tracking == "" {
+		return "", fmt.Errorf("no tracking branch is set for the current branch (%s)", branch.local)
 	}
+	log.Printf("Running the scanner on the current branch: %s (tracking: %s %s).", colorstring.Green(branch.local), branch.remote, branch.tracking)
+	return branch.tracking, nil
+}
 
-	var workflows []string
-	for workflow := range buildBitriseYML.Workflows {
-		workflows = append(workflows, workflow)
-	}
+// ScannerOptions configure the scanner run when a new bitrise.yml is generated.
+type ScannerOptions struct {
+	// Answers resolve the scanner options without prompts.
+	Answers ScannerAnswers
+	// Dir is the directory to scan, relative to the repository root, defaults to the root.
+	Dir string
+	// Exclude are glob patterns of the paths (relative to the scanned directory) or file names skipped by the scanner.
+	Exclude []string
+	// OutputDir is the directory to save the scan result and the selected config to, for debugging.
+	OutputDir    string
+	OutputFormat output.Format
+}
 
-	if len(workflows) == 1 {
-		log.Infof("Selecting workflow: %s", workflows[0])
-		return workflows[0], nil
+// scan runs the scanner on the configured directory, the project paths of the result are relative to the repository root.
+func scan(searchDir string, isPrivateRepo bool, options ScannerOptions) (scannerModels.ScanResultModel, bool, error) {
+	scanDir, relScanDir, err := scanDirectory(searchDir, options.Dir)
+	if err != nil {
+		return scannerModels.ScanResultModel{}, false, err
 	}
-
-	prompt := promptui.Select{
-		Label: "Select workflow to run in the first build",
-		Items: workflows,
-		Templates: &promptui.SelectTemplates{
-			Selected: "Selected workflow: {{ . }}",
-		},
+	if relScanDir != "." {
+		log.Printf("Scanning %s", relScanDir)
 	}
 
-	_, workflow, err := prompt.Run()
-	if err != nil {
-		return "", err
+	if len(options.Exclude) > 0 {
+		mirrorDir, err := mirrorScanDir(scanDir, options.Exclude)
+		if err != nil {
+			return scannerModels.ScanResultModel{}, false, err
+		}
+		defer func() {
+			if err := os.RemoveAll(mirrorDir); err != nil {
+				log.Warnf("Failed to remove temporary directory (%s), error: %s", mirrorDir, err)
+			}
+		}()
+		scanDir = mirrorDir
 	}
 
-	return workflow, nil
+	scanResult, found := scanner.GenerateScanResult(scanDir, isPrivateRepo)
+	if found {
+		relocateScanResult(&scanResult, relScanDir)
+	}
+	// saved with the relocated paths, before the mirrored scan directory is removed, the icons are read from there
+	saveScanResult(scanResult, options)
+	return scanResult, found, nil
 }
 
-func getBitriseYML(searchDir string, inputReader io.Reader, isPrivateRepo bool) (models.BitriseDataModel, string, error) {
+func getBitriseYML(searchDir string, inputReader io.Reader, isPrivateRepo bool, scannerOptions ScannerOptions) (models.BitriseDataModel, []byte, string, []string, error) {
 	potentialBitriseYMLFilePath := filepath.Join(searchDir, bitriseYMLName)
 	if exist, err := pathutil.IsPathExists(potentialBitriseYMLFilePath); err != nil {
-		return models.BitriseDataModel{}, "", fmt.Errorf("failed to check if file (%s) exists, error: %s", potentialBitriseYMLFilePath, err)
+		return models.BitriseDataModel{}, nil, "", nil, fmt.Errorf("failed to check if file (%s) exists, error: %s", potentialBitriseYMLFilePath, err)
 	} else if exist {
 		log.Printf("Found bitrise.yml in current directory.")
 	} else {
@@ -257,70 +300,91 @@ func getBitriseYML(searchDir string, inputReader io.Reader, isPrivateRepo bool)
 		},
 	}
 
-	_, answer, err := prompt.Run()
-	if err != nil {
-		return models.BitriseDataModel{}, "", fmt.Errorf("failed to get bitrise.yml, error: %s", err)
+	answer := optionRunScanner
+	if len(scannerOptions.Answers) == 0 {
+		var err error
+		if _, answer, err = prompt.Run(); err != nil {
+			return models.BitriseDataModel{}, nil, "", nil, fmt.Errorf("failed to get bitrise.yml, error: %s", err)
+		}
 	}
 
 	if answer == optionAlreadyExisting {
-		bitriseYML, err := selectBitriseYMLFile(inputReader, potentialBitriseYMLFilePath)
+		bitriseYML, content, err := selectBitriseYMLFile(inputReader, potentialBitriseYMLFilePath)
 		if err != nil {
-			return models.BitriseDataModel{}, "", fmt.Errorf("failed to select bitrise.yml, error: %s", err)
+			return models.BitriseDataModel{}, nil, "", nil, fmt.Errorf("failed to select bitrise.yml, error: %s", err)
 		}
 
 		branch, err := currentBranch(searchDir)
 		if err != nil {
-			return models.BitriseDataModel{}, "", fmt.Errorf("failed to get current branch, error: %s", err)
+			return models.BitriseDataModel{}, nil, "", nil, fmt.Errorf("failed to get current branch, error: %s", err)
 		}
 
 		branchName, err := askBranch(branch.tracking)
 		if err != nil {
-			return models.BitriseDataModel{}, "", fmt.Errorf("failed to ask for primary branch, error: %s", err)
+			return models.BitriseDataModel{}, nil, "", nil, fmt.Errorf("failed to ask for primary branch, error: %s", err)
 		}
 
-		return bitriseYML, branchName, nil
+		return bitriseYML, content, branchName, nil, nil
 	}
 
-	branch, err := checkBranch(searchDir, os.Stdin)
+	var branch string
+	var err error
+	if len(scannerOptions.Answers) > 0 {
+		branch, err = scannerBranch(searchDir)
+	} else {
+		branch, err = checkBranch(searchDir, os.Stdin)
+	}
 	if err != nil {
-		return models.BitriseDataModel{}, "", fmt.Errorf("failed to check repository branch: %s", err)
+		return models.BitriseDataModel{}, nil, "", nil, fmt.Errorf("failed to check repository branch: %s", err)
 	}
 
 	fmt.Println()
 
-	scanResult, found := scanner.GenerateScanResult(searchDir, isPrivateRepo)
+	scanResult, found, err := scan(searchDir, isPrivateRepo, scannerOptions)
+	if err != nil {
+		return models.BitriseDataModel{}, nil, "", nil, err
+	}
+	var platforms []string
 	if !found {
 		log.Infof("Projects not found in repository. Select manual configuration.")
 		scanResult, err = scanner.ManualConfig()
 		if err != nil {
-			return models.BitriseDataModel{}, "", fmt.Errorf("failed to get manual configurations, error: %s", err)
+			return models.BitriseDataModel{}, nil, "", nil, fmt.Errorf("failed to get manual configurations, error: %s", err)
 		}
 	} else {
-		var platforms []string
 		for scanner := range scanResult.ScannerToOptionRoot {
 			platforms = append(platforms, scanner)
 		}
+		sort.Strings(platforms)
 		log.Printf("Project(s) found in the repository: %s", colorstring.Green(strings.Join(platforms, ", ")))
 	}
-	bitriseYML, err := scanner.AskForConfig(scanResult)
+	var bitriseYML models.BitriseDataModel
+	if len(scannerOptions.Answers) > 0 {
+		bitriseYML, err = resolveScannerConfig(scanResult, scannerOptions.Answers)
+	} else {
+		bitriseYML, err = scanner.AskForConfig(scanResult)
+	}
 	if err != nil {
-		return models.BitriseDataModel{}, "", fmt.Errorf("failed to get exact configuration from scanner result, error: %s", err)
+		return models.BitriseDataModel{}, nil, "", nil, fmt.Errorf("failed to get exact configuration from scanner result, error: %s", err)
 	}
-	return bitriseYML, branch, nil
+	saveScanConfig(bitriseYML, scannerOptions)
+	return bitriseYML, nil, branch, platforms, nil
 }
 
-// BitriseYML ...
-func BitriseYML(searchDir string, isPrivateRepo bool) (models.BitriseDataModel, string, string, error) {
+// BitriseYML returns the bitrise.yml data model, the original file content if an existing bitrise.yml was selected,
+// the pipeline or workflow for the first build, the default branch and the platforms detected by the scanner.
+// If scanner answers are given, the scanner runs on the current branch and its options are resolved without prompts.
+func BitriseYML(searchDir string, isPrivateRepo bool, scannerOptions ScannerOptions) (models.BitriseDataModel, []byte, BuildTarget, string, []string, error) {
 	fmt.Println()
 	log.Infof("SETUP BITRISE.YML")
-	bitriseYML, branch, err := getBitriseYML(searchDir, os.Stdin, isPrivateRepo)
+	bitriseYML, content, branch, platforms, err := getBitriseYML(searchDir, os.Stdin, isPrivateRepo, scannerOptions)
 	if err != nil {
-		return models.BitriseDataModel{}, "", "", err
+		return models.BitriseDataModel{}, nil, BuildTarget{}, "", nil, err
 	}
 
-	workflow, err := selectWorkflow(bitriseYML, os.Stdin)
+	target, err := selectBuildTarget(bitriseYML, os.Stdin)
 	if err != nil {
-		return models.BitriseDataModel{}, "", "", fmt.Errorf("failed to select workflow, error: %s", err)
+		return models.BitriseDataModel{}, nil, BuildTarget{}, "", nil, fmt.Errorf("failed to select the first build's target, error: %s", err)
 	}
-	return bitriseYML, workflow, branch, nil
+	return bitriseYML, content, target, branch, platforms, nil
 }
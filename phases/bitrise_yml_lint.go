@@ -0,0 +1,355 @@
+package phases
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/bitrise/v2/models"
+	envmanModels "github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/sliceutil"
+)
+
+// App env vars the steps generated for the project type rely on.
+var requiredEnvVarsByProjectType = map[string][]string{
+	"ios":     {"BITRISE_PROJECT_PATH", "BITRISE_SCHEME"},
+	"macos":   {"BITRISE_PROJECT_PATH", "BITRISE_SCHEME"},
+	"android": {"PROJECT_LOCATION"},
+	"flutter": {"BITRISE_FLUTTER_PROJECT_LOCATION"},
+}
+
+// Prefix of the env vars exposed by the Bitrise CLI and the steps.
+const builtinEnvVarPrefix = "BITRISE_"
+
+// Env vars exposed by the build environment and the secrets uploaded by the tool,
+// other names (e.g. ANDROID_KEYSTORE_PASSWORD) are expected to be defined by the user.
+var builtinEnvVars = []string{
+	"CI", "PR", "PULL_REQUEST_ID", "PULL_REQUEST_REPOSITORY_URL", "PULL_REQUEST_MERGE_BRANCH", "PULL_REQUEST_HEAD_BRANCH",
+	"BRANCH", "BRANCH_DEST", "BRANCH_REPO_OWNER", "BRANCH_DEST_REPO_OWNER", "COMMIT", "TAG",
+	"GIT_REPOSITORY_URL", "GIT_CLONE_COMMIT_HASH", "GIT_CLONE_COMMIT_MESSAGE_SUBJECT", "GIT_CLONE_COMMIT_MESSAGE_BODY",
+	"GIT_CLONE_COMMIT_AUTHOR_NAME", "GIT_CLONE_COMMIT_AUTHOR_EMAIL", "GIT_CLONE_COMMIT_COMMITER_NAME", "GIT_CLONE_COMMIT_COMMITER_EMAIL",
+	"GIT_CLONE_COMMIT_COUNT", "ANDROID_HOME", "ANDROID_SDK_ROOT", "ANDROID_NDK_HOME", "SSH_RSA_PRIVATE_KEY", KnownHostsSecretKey,
+	"HOME", "PATH", "PWD", "USER", "TMPDIR", "JAVA_HOME", "SOURCE_DIR",
+}
+
+var envVarReferencePattern = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)\}?`)
+
+// LintResult contains the issues found in a bitrise.yml,
+// errors make the builds fail so the bitrise.yml should not be uploaded.
+type LintResult struct {
+	Errors   []string
+	Warnings []string
+}
+
+func (r *LintResult) errorf(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r *LintResult) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Err returns the lint errors as a single error, or nil if there are none.
+func (r LintResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("bitrise.yml is not valid:\n- %s", strings.Join(r.Errors, "\n- "))
+}
+
+func logLintResult(result LintResult) {
+	for _, e := range result.Errors {
+		log.Errorf("- %s", e)
+	}
+	for _, w := range result.Warnings {
+		log.Warnf("- %s", w)
+	}
+}
+
+// lintStep is a step of a workflow with the step inputs.
+type lintStep struct {
+	id     string
+	inputs []envmanModels.EnvironmentItemModel
+}
+
+// workflowSteps returns the steps of the workflow (without the before_run and after_run workflows).
+func workflowSteps(bitriseYML models.BitriseDataModel, workflowID string) []lintStep {
+	var steps []lintStep
+	for _, item := range bitriseYML.Workflows[workflowID].Steps {
+		key, t, err := item.GetKeyAndType()
+		if err != nil {
+			continue
+		}
+		switch t {
+		case models.StepListItemTypeStep:
+			step, err := item.GetStep()
+			if err != nil {
+				continue
+			}
+			steps = append(steps, lintStep{id: stepIDFromKey(key, bitriseYML.DefaultStepLibSource), inputs: step.Inputs})
+		case models.StepListItemTypeBundle:
+			var inputs []envmanModels.EnvironmentItemModel
+			if bundle, err := item.GetBundle(); err == nil && bundle != nil {
+				inputs = bundle.Inputs
+			}
+			for _, id := range bundleStepIDs(bitriseYML, key, nil) {
+				steps = append(steps, lintStep{id: id, inputs: inputs})
+			}
+		case models.StepListItemTypeWith:
+			with, err := item.GetWith()
+			if err != nil {
+				continue
+			}
+			for _, stepItem := range with.Steps {
+				if stepKey, step, err := stepItem.GetStepIDAndStep(); err == nil {
+					steps = append(steps, lintStep{id: stepIDFromKey(stepKey, bitriseYML.DefaultStepLibSource), inputs: step.Inputs})
+				}
+			}
+		}
+	}
+	return steps
+}
+
+func sortedWorkflowIDs(bitriseYML models.BitriseDataModel) []string {
+	var ids []string
+	for id := range bitriseYML.Workflows {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func sortedPipelineIDs(bitriseYML models.BitriseDataModel) []string {
+	var ids []string
+	for id := range bitriseYML.Pipelines {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// workflowCycle returns the first before_run/after_run reference cycle starting from the workflow, e.g. [a b a].
+func workflowCycle(bitriseYML models.BitriseDataModel, workflowID string, path []string) []string {
+	if i := sliceutil.IndexOfStringInSlice(workflowID, path); i != -1 {
+		return append(append([]string{}, path[i:]...), workflowID)
+	}
+	workflow, ok := bitriseYML.Workflows[workflowID]
+	if !ok {
+		return nil
+	}
+	path = append(path, workflowID)
+	for _, id := range append(append([]string{}, workflow.BeforeRun...), workflow.AfterRun...) {
+		if cycle := workflowCycle(bitriseYML, id, path); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+func lintWorkflowReferences(bitriseYML models.BitriseDataModel, result *LintResult) {
+	reportedCycles := map[string]bool{}
+	for _, id := range sortedWorkflowIDs(bitriseYML) {
+		workflow := bitriseYML.Workflows[id]
+		for _, ref := range workflow.BeforeRun {
+			if _, ok := bitriseYML.Workflows[ref]; !ok {
+				result.errorf("workflow %s: before_run references a non-existent workflow: %s", id, ref)
+			}
+		}
+		for _, ref := range workflow.AfterRun {
+			if _, ok := bitriseYML.Workflows[ref]; !ok {
+				result.errorf("workflow %s: after_run references a non-existent workflow: %s", id, ref)
+			}
+		}
+
+		if cycle := workflowCycle(bitriseYML, id, nil); cycle != nil {
+			members := append([]string{}, cycle[1:]...)
+			sort.Strings(members)
+			if key := strings.Join(members, ","); !reportedCycles[key] {
+				reportedCycles[key] = true
+				result.errorf("workflow reference cycle: %s", strings.Join(cycle, " -> "))
+			}
+		}
+	}
+}
+
+func lintTriggers(bitriseYML models.BitriseDataModel, result *LintResult) {
+	if _, err := bitriseYML.TriggerMap.Validate(sortedWorkflowIDs(bitriseYML), sortedPipelineIDs(bitriseYML)); err != nil {
+		result.errorf("trigger_map: %s", err)
+	}
+
+	for _, pipelineID := range sortedPipelineIDs(bitriseYML) {
+		pipeline := bitriseYML.Pipelines[pipelineID]
+		for _, stageItem := range pipeline.Stages {
+			for stageID := range stageItem {
+				stage, ok := bitriseYML.Stages[stageID]
+				if !ok {
+					result.errorf("pipeline %s: references a non-existent stage: %s", pipelineID, stageID)
+					continue
+				}
+				for _, workflowItem := range stage.Workflows {
+					for workflowID := range workflowItem {
+						if _, ok := bitriseYML.Workflows[workflowID]; !ok {
+							result.errorf("stage %s: references a non-existent workflow: %s", stageID, workflowID)
+						}
+					}
+				}
+			}
+		}
+
+		var ids []string
+		for id := range pipeline.Workflows {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			workflowID := id
+			if uses := pipeline.Workflows[id].Uses; uses != "" {
+				workflowID = uses
+			}
+			if _, ok := bitriseYML.Workflows[workflowID]; !ok {
+				result.errorf("pipeline %s: references a non-existent workflow: %s", pipelineID, workflowID)
+			}
+			for _, dependency := range pipeline.Workflows[id].DependsOn {
+				if _, ok := pipeline.Workflows[dependency]; !ok {
+					result.errorf("pipeline %s: workflow %s depends on a workflow which is not part of the pipeline: %s", pipelineID, id, dependency)
+				}
+			}
+		}
+	}
+}
+
+func lintSteps(bitriseYML models.BitriseDataModel, result *LintResult) {
+	for _, id := range sortedWorkflowIDs(bitriseYML) {
+		workflow := bitriseYML.Workflows[id]
+		if len(workflow.Steps) == 0 && len(workflow.BeforeRun) == 0 && len(workflow.AfterRun) == 0 {
+			result.warnf("workflow %s: has no steps", id)
+			continue
+		}
+
+		var reported []string
+		steps := workflowSteps(bitriseYML, id)
+		for i, step := range steps {
+			if sliceutil.IsStringInSlice(step.id, reported) {
+				continue
+			}
+			for _, other := range steps[i+1:] {
+				// The same step is often run multiple times with different inputs (e.g. script), identical ones are likely a mistake.
+				if other.id == step.id && reflect.DeepEqual(other.inputs, step.inputs) {
+					result.warnf("workflow %s: step %s is added multiple times with the same inputs", id, step.id)
+					reported = append(reported, step.id)
+					break
+				}
+			}
+		}
+	}
+}
+
+func envKeys(envs []envmanModels.EnvironmentItemModel) []string {
+	var keys []string
+	for _, env := range envs {
+		if key, _, err := env.GetKeyValuePair(); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func envValues(envs []envmanModels.EnvironmentItemModel) []string {
+	var values []string
+	for _, env := range envs {
+		if _, value, err := env.GetKeyValuePair(); err == nil {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func isBuiltinEnvVar(key string) bool {
+	return sliceutil.IsStringInSlice(key, builtinEnvVars) || strings.HasPrefix(key, builtinEnvVarPrefix)
+}
+
+// referencedEnvVars returns the env vars referenced in the env and step input values of the bitrise.yml.
+// Multiline values (e.g. scripts) are skipped as they can define their own variables.
+func referencedEnvVars(bitriseYML models.BitriseDataModel) []string {
+	values := envValues(bitriseYML.App.Environments)
+	for _, id := range sortedWorkflowIDs(bitriseYML) {
+		values = append(values, envValues(bitriseYML.Workflows[id].Environments)...)
+		for _, step := range workflowSteps(bitriseYML, id) {
+			values = append(values, envValues(step.inputs)...)
+		}
+	}
+
+	var keys []string
+	for _, value := range values {
+		if strings.Contains(value, "\n") {
+			continue
+		}
+		for _, match := range envVarReferencePattern.FindAllStringSubmatch(value, -1) {
+			if !sliceutil.IsStringInSlice(match[1], keys) {
+				keys = append(keys, match[1])
+			}
+		}
+	}
+	return keys
+}
+
+// definedEnvVars returns the env vars defined in the app, workflow and step bundle envs of the bitrise.yml.
+func definedEnvVars(bitriseYML models.BitriseDataModel) []string {
+	defined := envKeys(bitriseYML.App.Environments)
+	for _, workflow := range bitriseYML.Workflows {
+		defined = append(defined, envKeys(workflow.Environments)...)
+	}
+	for _, bundle := range bitriseYML.StepBundles {
+		defined = append(defined, envKeys(bundle.Inputs)...)
+		defined = append(defined, envKeys(bundle.Environments)...)
+	}
+	return defined
+}
+
+// undefinedEnvVars returns the referenced env vars which are neither defined in the bitrise.yml nor provided by Bitrise,
+// nor required by the project type (those are linted separately), in alphabetical order.
+func undefinedEnvVars(bitriseYML models.BitriseDataModel, projectType string) []string {
+	defined := definedEnvVars(bitriseYML)
+
+	var undefined []string
+	for _, key := range referencedEnvVars(bitriseYML) {
+		if !sliceutil.IsStringInSlice(key, defined) && !isBuiltinEnvVar(key) && !sliceutil.IsStringInSlice(key, requiredEnvVarsByProjectType[projectType]) {
+			undefined = append(undefined, key)
+		}
+	}
+	sort.Strings(undefined)
+	return undefined
+}
+
+func lintEnvVars(bitriseYML models.BitriseDataModel, projectType string, result *LintResult) {
+	defined := definedEnvVars(bitriseYML)
+	referenced := referencedEnvVars(bitriseYML)
+
+	for _, key := range requiredEnvVarsByProjectType[projectType] {
+		if sliceutil.IsStringInSlice(key, defined) {
+			continue
+		}
+		if sliceutil.IsStringInSlice(key, referenced) {
+			result.errorf("app env var %s is used by the steps, but not defined for the %s project", key, projectType)
+		} else {
+			result.warnf("app env var %s is not defined, it is usually required for %s projects", key, projectType)
+		}
+	}
+
+	if unresolved := undefinedEnvVars(bitriseYML, projectType); len(unresolved) > 0 {
+		result.warnf("env vars not defined in bitrise.yml, make sure they are added as secrets or exported by a previous step: %s", strings.Join(unresolved, ", "))
+	}
+}
+
+// LintBitriseYML checks the bitrise.yml for issues which would make the builds fail or behave unexpectedly.
+func LintBitriseYML(bitriseYML models.BitriseDataModel, projectType string) LintResult {
+	var result LintResult
+	lintWorkflowReferences(bitriseYML, &result)
+	lintTriggers(bitriseYML, &result)
+	lintSteps(bitriseYML, &result)
+	lintEnvVars(bitriseYML, projectType, &result)
+	return result
+}
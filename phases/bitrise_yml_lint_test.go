@@ -0,0 +1,144 @@
+package phases
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestLintBitriseYML(t *testing.T) {
+	tests := []struct {
+		name         string
+		projectType  string
+		bitriseYML   string
+		wantErrors   []string
+		wantWarnings []string
+	}{
+		{
+			name:        "valid",
+			projectType: "ios",
+			bitriseYML: `app:
+  envs:
+  - BITRISE_PROJECT_PATH: App.xcodeproj
+  - BITRISE_SCHEME: App
+workflows:
+  primary:
+    steps:
+    - git-clone@8: {}
+    - xcode-test@5:
+        inputs:
+        - project_path: $BITRISE_PROJECT_PATH
+        - scheme: $BITRISE_SCHEME
+        - destination: $BITRISE_XCODE_DESTINATION
+    - script@1:
+        inputs:
+        - content: |-
+            for f in $FILES; do
+              echo $f
+            done
+    - script@1:
+        inputs:
+        - content: echo done
+`,
+		},
+		{
+			name:        "workflow references",
+			projectType: "other",
+			bitriseYML: `workflows:
+  primary:
+    before_run:
+    - _setup
+    after_run:
+    - _missing
+    steps:
+    - git-clone@8: {}
+  _setup:
+    before_run:
+    - primary
+`,
+			wantErrors: []string{
+				"workflow reference cycle: _setup -> primary -> _setup",
+				"workflow primary: after_run references a non-existent workflow: _missing",
+			},
+		},
+		{
+			name:        "trigger and pipeline targets",
+			projectType: "other",
+			bitriseYML: `trigger_map:
+- push_branch: main
+  workflow: deploy
+pipelines:
+  ci:
+    workflows:
+      primary: {}
+      test:
+        depends_on:
+        - build
+workflows:
+  primary:
+    steps:
+    - git-clone@8: {}
+`,
+			wantErrors: []string{
+				"trigger_map: trigger item #1: non-existent workflow defined as trigger target: deploy",
+				"pipeline ci: references a non-existent workflow: test",
+				"pipeline ci: workflow test depends on a workflow which is not part of the pipeline: build",
+			},
+		},
+		{
+			name:        "steps",
+			projectType: "other",
+			bitriseYML: `workflows:
+  empty: {}
+  primary:
+    steps:
+    - git-clone@8: {}
+    - git-clone@8: {}
+`,
+			wantWarnings: []string{
+				"workflow empty: has no steps",
+				"workflow primary: step git-clone is added multiple times with the same inputs",
+			},
+		},
+		{
+			name:        "env vars",
+			projectType: "android",
+			bitriseYML: `workflows:
+  primary:
+    envs:
+    - VARIANT: release
+    steps:
+    - android-build@1:
+        inputs:
+        - project_location: $PROJECT_LOCATION
+        - variant: $VARIANT
+        - module: ${MODULE}
+        - arguments: $GRADLE_ARGS $BITRISE_GRADLE_ARGS
+        - sdk: $ANDROID_HOME
+    - sign-apk@1:
+        inputs:
+        - keystore_password: $ANDROID_KEYSTORE_PASSWORD
+        - passphrase: $SSH_PASSPHRASE
+        - commit: $GIT_CLONE_COMMIT_HASH
+`,
+			wantErrors: []string{
+				"app env var PROJECT_LOCATION is used by the steps, but not defined for the android project",
+			},
+			wantWarnings: []string{
+				"env vars not defined in bitrise.yml, make sure they are added as secrets or exported by a previous step: ANDROID_KEYSTORE_PASSWORD, GRADLE_ARGS, MODULE, SSH_PASSPHRASE",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bitriseYML models.BitriseDataModel
+			require.NoError(t, yaml.Unmarshal([]byte(tt.bitriseYML), &bitriseYML))
+
+			result := LintBitriseYML(bitriseYML, tt.projectType)
+			require.Equal(t, tt.wantErrors, result.Errors)
+			require.Equal(t, tt.wantWarnings, result.Warnings)
+		})
+	}
+}
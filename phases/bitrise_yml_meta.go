@@ -0,0 +1,327 @@
+package phases
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/bitrise-io/go-utils/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	metaKey      = "meta"
+	bitriseIOKey = "bitrise.io"
+)
+
+// toStringMap converts the decoded YAML mapping to a map with string keys,
+// nested mappings decoded by yaml.v2 have interface{} keys.
+func toStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for k, v := range m {
+			converted[fmt.Sprint(k)] = v
+		}
+		return converted, true
+	case map[string]string:
+		converted := map[string]interface{}{}
+		for k, v := range m {
+			converted[k] = v
+		}
+		return converted, true
+	}
+	return nil, false
+}
+
+// BitriseIOMeta returns the meta.bitrise.io.<key> value of the bitrise.yml, or an empty string if it is not set.
+func BitriseIOMeta(bitriseYML models.BitriseDataModel, key string) string {
+	bitriseIO, ok := toStringMap(bitriseYML.Meta[bitriseIOKey])
+	if !ok {
+		return ""
+	}
+	value, ok := bitriseIO[key]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// SetBitriseIOMeta sets the meta.bitrise.io.<key> value of the bitrise.yml,
+// keeping every other key of the meta section.
+func SetBitriseIOMeta(bitriseYML *models.BitriseDataModel, key, value string) {
+	if bitriseYML.Meta == nil {
+		bitriseYML.Meta = map[string]interface{}{}
+	}
+
+	bitriseIO, ok := toStringMap(bitriseYML.Meta[bitriseIOKey])
+	if !ok {
+		if existing := bitriseYML.Meta[bitriseIOKey]; existing != nil {
+			log.Warnf("Invalid %s.%s section in bitrise.yml (%v), overwriting it", metaKey, bitriseIOKey, existing)
+		}
+		bitriseIO = map[string]interface{}{}
+	}
+
+	bitriseIO[key] = value
+	bitriseYML.Meta[bitriseIOKey] = bitriseIO
+}
+
+// SetStack writes the selected stack into the bitrise.yml meta section,
+// warning if the bitrise.yml declared a different stack.
+func SetStack(bitriseYML *models.BitriseDataModel, stack string) {
+	if declared := BitriseIOMeta(*bitriseYML, "stack"); declared != "" && declared != stack {
+		log.Warnf("The bitrise.yml declares the %s stack, it is replaced by the selected stack: %s", declared, stack)
+	}
+	SetBitriseIOMeta(bitriseYML, "stack", stack)
+}
+
+func mappingKeyValue(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func isBlockMapping(node *yaml.Node) bool {
+	return node.Kind == yaml.MappingNode && node.Style&yaml.FlowStyle == 0
+}
+
+// isEmpty reports whether the node is a key without a value (e.g. "meta:"),
+// which can be turned into a block mapping by inserting the child lines.
+func isEmpty(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null" && node.Value == ""
+}
+
+func isPlainSafe(value string) bool {
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &node); err != nil || len(node.Content) != 1 {
+		return false
+	}
+	scalar := node.Content[0]
+	return scalar.Kind == yaml.ScalarNode && scalar.Tag == "!!str" && scalar.Value == value && !strings.ContainsAny(value, "#\n")
+}
+
+func yamlScalar(value string) string {
+	if isPlainSafe(value) {
+		return value
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+// bitriseYMLLines is the line based representation of a bitrise.yml,
+// used to insert and replace lines at the positions reported by the yaml.v3 decoder.
+type bitriseYMLLines struct {
+	lines   []string
+	newline string
+}
+
+func newBitriseYMLLines(content []byte) bitriseYMLLines {
+	newline := "\n"
+	if bytes.Contains(content, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	text := strings.TrimSuffix(string(content), newline)
+	return bitriseYMLLines{
+		lines:   strings.Split(text, newline),
+		newline: newline,
+	}
+}
+
+func (l *bitriseYMLLines) insertAfter(line int, newLines ...string) {
+	var lines []string
+	lines = append(lines, l.lines[:line]...)
+	lines = append(lines, newLines...)
+	lines = append(lines, l.lines[line:]...)
+	l.lines = lines
+}
+
+func (l *bitriseYMLLines) bytes() []byte {
+	return []byte(strings.Join(l.lines, l.newline) + l.newline)
+}
+
+// replaceScalar replaces the line of the key and its single line scalar value with the entry,
+// keeping the indentation and any trailing comment of the line.
+func (l *bitriseYMLLines) replaceScalar(keyNode, valueNode *yaml.Node, entry string) {
+	line := []rune(l.lines[keyNode.Line-1])
+	rest := string(line[valueNode.Column-1:])
+	if valueNode.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		quote := rest[0]
+		end := 1
+		for end < len(rest) {
+			if rest[end] == '\\' && quote == '"' {
+				end += 2
+				continue
+			}
+			if rest[end] == quote {
+				if quote == '\'' && end+1 < len(rest) && rest[end+1] == '\'' {
+					end += 2
+					continue
+				}
+				break
+			}
+			end++
+		}
+		rest = rest[min(end+1, len(rest)):]
+	} else {
+		rest = strings.TrimPrefix(rest, valueNode.Value)
+	}
+	l.lines[keyNode.Line-1] = string(line[:keyNode.Column-1]) + entry + rest
+}
+
+func childIndent(key, value *yaml.Node) string {
+	if value != nil && value.Kind == yaml.MappingNode && len(value.Content) > 0 {
+		return strings.Repeat(" ", value.Content[0].Column-1)
+	}
+	return strings.Repeat(" ", key.Column-1+2)
+}
+
+// setBitriseIOMetaFallback sets meta.bitrise.io.<key> by re-encoding the YAML node tree,
+// used when the surrounding nodes can not be patched line by line (e.g. flow style mappings).
+// Comments, key order and anchors are kept, but the indentation might change.
+func setBitriseIOMetaFallback(doc *yaml.Node, key, value string) ([]byte, error) {
+	parent := doc.Content[0]
+	for _, k := range []string{metaKey, bitriseIOKey} {
+		_, child := mappingKeyValue(parent, k)
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, child)
+		} else if child.Kind != yaml.MappingNode {
+			*child = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		parent = child
+	}
+
+	if _, node := mappingKeyValue(parent, key); node != nil {
+		*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	} else {
+		parent.Content = append(parent.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+		)
+	}
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// setBitriseIOMeta sets the meta.bitrise.io.<key> value of the given bitrise.yml content.
+// Only the affected lines are modified, the rest of the file (comments, key order, anchors and formatting) is kept as is.
+func setBitriseIOMeta(content []byte, key, value string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse bitrise.yml: %s", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("bitrise.yml root is not a mapping")
+	}
+	root := doc.Content[0]
+	if !isBlockMapping(root) {
+		return setBitriseIOMetaFallback(&doc, key, value)
+	}
+
+	lines := newBitriseYMLLines(content)
+	entry := fmt.Sprintf("%s: %s", key, yamlScalar(value))
+
+	metaKeyNode, meta := mappingKeyValue(root, metaKey)
+	if meta == nil {
+		indent := ""
+		if len(root.Content) > 0 {
+			indent = strings.Repeat(" ", root.Content[0].Column-1)
+		}
+		lines.insertAfter(len(lines.lines),
+			indent+metaKey+":",
+			indent+"  "+bitriseIOKey+":",
+			indent+"    "+entry,
+		)
+		return lines.bytes(), nil
+	}
+	if !isBlockMapping(meta) && !isEmpty(meta) {
+		return setBitriseIOMetaFallback(&doc, key, value)
+	}
+
+	bitriseIOKeyNode, bitriseIO := mappingKeyValue(meta, bitriseIOKey)
+	if bitriseIO == nil {
+		indent := childIndent(metaKeyNode, meta)
+		lines.insertAfter(metaKeyNode.Line,
+			indent+bitriseIOKey+":",
+			indent+"  "+entry,
+		)
+		return lines.bytes(), nil
+	}
+	if !isBlockMapping(bitriseIO) && !isEmpty(bitriseIO) {
+		return setBitriseIOMetaFallback(&doc, key, value)
+	}
+
+	keyNode, valueNode := mappingKeyValue(bitriseIO, key)
+	if valueNode == nil {
+		lines.insertAfter(bitriseIOKeyNode.Line, childIndent(bitriseIOKeyNode, bitriseIO)+entry)
+		return lines.bytes(), nil
+	}
+	if valueNode.Kind != yaml.ScalarNode || isEmpty(valueNode) || valueNode.Line != keyNode.Line {
+		return setBitriseIOMetaFallback(&doc, key, value)
+	}
+
+	lines.replaceScalar(keyNode, valueNode, entry)
+
+	return lines.bytes(), nil
+}
+
+const projectTypeKey = "project_type"
+
+// setProjectTypeFallback sets the project_type by re-encoding the YAML node tree,
+// used when it can not be patched line by line (e.g. multi line value).
+func setProjectTypeFallback(doc *yaml.Node, node *yaml.Node, projectType string) ([]byte, error) {
+	*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: projectType}
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// setProjectType replaces the project_type of the given bitrise.yml content, only its line is modified.
+// A bitrise.yml without project_type is kept as is, the app's project type is used for it.
+func setProjectType(content []byte, projectType string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse bitrise.yml: %s", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("bitrise.yml root is not a mapping")
+	}
+	root := doc.Content[0]
+
+	keyNode, valueNode := mappingKeyValue(root, projectTypeKey)
+	if valueNode == nil || valueNode.Value == projectType {
+		return content, nil
+	}
+	if !isBlockMapping(root) || valueNode.Kind != yaml.ScalarNode || isEmpty(valueNode) || valueNode.Line != keyNode.Line {
+		return setProjectTypeFallback(&doc, valueNode, projectType)
+	}
+
+	lines := newBitriseYMLLines(content)
+	lines.replaceScalar(keyNode, valueNode, fmt.Sprintf("%s: %s", projectTypeKey, yamlScalar(projectType)))
+	return lines.bytes(), nil
+}
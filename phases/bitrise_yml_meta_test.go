@@ -0,0 +1,190 @@
+package phases
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_setBitriseIOMeta(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "no meta",
+			content: `format_version: "13"
+# primary workflow
+workflows:
+  primary: {}
+`,
+			want: `format_version: "13"
+# primary workflow
+workflows:
+  primary: {}
+meta:
+  bitrise.io:
+    stack: linux-docker-android-22.04
+`,
+		},
+		{
+			name: "meta without bitrise.io",
+			content: `format_version: "13"
+meta:
+    custom: value # keep
+workflows:
+  primary: {}
+`,
+			want: `format_version: "13"
+meta:
+    bitrise.io:
+      stack: linux-docker-android-22.04
+    custom: value # keep
+workflows:
+  primary: {}
+`,
+		},
+		{
+			name: "bitrise.io without stack",
+			content: `meta:
+  bitrise.io:
+    machine_type_id: g2.4core
+workflows:
+  primary: {}
+`,
+			want: `meta:
+  bitrise.io:
+    stack: linux-docker-android-22.04
+    machine_type_id: g2.4core
+workflows:
+  primary: {}
+`,
+		},
+		{
+			name: "existing stack",
+			content: `meta:
+  bitrise.io:
+    stack: osx-xcode-16.0.x # selected stack
+    machine_type_id: g2.4core
+`,
+			want: `meta:
+  bitrise.io:
+    stack: linux-docker-android-22.04 # selected stack
+    machine_type_id: g2.4core
+`,
+		},
+		{
+			name: "existing quoted stack",
+			content: `meta:
+  bitrise.io:
+    stack: "osx-xcode-16.0.x"
+`,
+			want: `meta:
+  bitrise.io:
+    stack: linux-docker-android-22.04
+`,
+		},
+		{
+			name: "flow style meta",
+			content: `meta: {bitrise.io: {stack: osx-xcode-16.0.x}}
+`,
+			want: `meta: {bitrise.io: {stack: linux-docker-android-22.04}}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setBitriseIOMeta([]byte(tt.content), "stack", "linux-docker-android-22.04")
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestSetStack(t *testing.T) {
+	bitriseYML := models.BitriseDataModel{
+		Meta: map[string]interface{}{
+			"bitrise.io": map[interface{}]interface{}{
+				"stack":           "osx-xcode-16.0.x",
+				"machine_type_id": "g2.4core",
+			},
+			"custom_tool": map[interface{}]interface{}{
+				"enabled": true,
+			},
+		},
+	}
+
+	SetStack(&bitriseYML, "linux-docker-android-22.04")
+
+	require.Equal(t, map[string]interface{}{
+		"bitrise.io": map[string]interface{}{
+			"stack":           "linux-docker-android-22.04",
+			"machine_type_id": "g2.4core",
+		},
+		"custom_tool": map[interface{}]interface{}{
+			"enabled": true,
+		},
+	}, bitriseYML.Meta)
+	require.Equal(t, "linux-docker-android-22.04", BitriseIOMeta(bitriseYML, "stack"))
+
+	empty := models.BitriseDataModel{}
+	SetStack(&empty, "linux-docker-android-22.04")
+	require.Equal(t, "linux-docker-android-22.04", BitriseIOMeta(empty, "stack"))
+}
+
+func Test_setProjectType(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "replaced in place",
+			content: `format_version: "13"
+project_type: flutter # detected
+workflows:
+  primary: {}
+`,
+			want: `format_version: "13"
+project_type: android # detected
+workflows:
+  primary: {}
+`,
+		},
+		{
+			name: "quoted",
+			content: `format_version: "13"
+project_type: 'flutter'
+`,
+			want: `format_version: "13"
+project_type: android
+`,
+		},
+		{
+			name: "missing",
+			content: `format_version: "13"
+workflows:
+  primary: {}
+`,
+			want: `format_version: "13"
+workflows:
+  primary: {}
+`,
+		},
+		{
+			name:    "flow style",
+			content: `{format_version: "13", project_type: flutter}`,
+			want: `{format_version: "13", project_type: android}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setProjectType([]byte(tt.content), "android")
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
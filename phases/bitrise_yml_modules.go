@@ -0,0 +1,116 @@
+package phases
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/bitrise/v2/configmerge"
+	bitriseLog "github.com/bitrise-io/bitrise/v2/log"
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"gopkg.in/yaml.v2"
+)
+
+// moduleReader reads the config modules from the local repository with readLocal,
+// modules of other repositories are cloned by the bitrise CLI's reader.
+type moduleReader struct {
+	remote    configmerge.ConfigReader
+	readLocal func(pth string) ([]byte, error)
+}
+
+// Read ...
+func (r moduleReader) Read(ref configmerge.ConfigReference) ([]byte, error) {
+	if ref.IsLocalReference() {
+		return r.readLocal(ref.Path)
+	}
+	return r.remote.Read(ref)
+}
+
+// CleanupRepoDirs ...
+func (r moduleReader) CleanupRepoDirs() error {
+	return r.remote.CleanupRepoDirs()
+}
+
+// isModularBitriseYML reports whether the bitrise.yml includes config modules.
+func isModularBitriseYML(content []byte) bool {
+	var config configmerge.ConfigModule
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return false
+	}
+	return len(config.Include) > 0
+}
+
+func configModulePaths(tree models.ConfigFileTreeModel) []string {
+	var paths []string
+	for _, include := range tree.Includes {
+		paths = append(paths, include.Path)
+		paths = append(paths, configModulePaths(include)...)
+	}
+	return paths
+}
+
+// mergeBitriseYML merges the config modules included by the main bitrise.yml into a single configuration.
+func mergeBitriseYML(mainPth string, readLocal func(pth string) ([]byte, error)) ([]byte, error) {
+	logger := bitriseLog.NewLogger(bitriseLog.LoggerOpts{
+		LoggerType: bitriseLog.ConsoleLogger,
+		Producer:   bitriseLog.BitriseCLI,
+		Writer:     os.Stdout,
+	})
+
+	remote, err := configmerge.NewConfigReader(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	merger := configmerge.NewMerger(moduleReader{remote: remote, readLocal: readLocal}, logger)
+	merged, tree, err := merger.MergeConfig(mainPth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge config modules, error: %s", err)
+	}
+
+	log.Printf("Merged config modules: %s", strings.Join(configModulePaths(*tree), ", "))
+	return []byte(merged), nil
+}
+
+// mergeLocalBitriseYML merges the modules of a bitrise.yml on the file system,
+// local include paths are relative to the directory of the bitrise.yml.
+func mergeLocalBitriseYML(pth string) ([]byte, error) {
+	absPth, err := filepath.Abs(pth)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(absPth)
+
+	return mergeBitriseYML(absPth, func(modulePth string) ([]byte, error) {
+		if !filepath.IsAbs(modulePth) {
+			modulePth = filepath.Join(dir, modulePth)
+		}
+		return os.ReadFile(modulePth)
+	})
+}
+
+// gitShow returns the content of the file at the given revision, the path is relative to the repository root.
+func gitShow(searchDir, revision, pth string) ([]byte, error) {
+	pth = strings.TrimPrefix(path.Clean(filepath.ToSlash(pth)), "./")
+
+	show := command.New("git", "show", revision+":"+pth).SetDir(searchDir)
+	var stderr bytes.Buffer
+	show.SetStderr(&stderr)
+	content, err := show.GetCmd().Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s not found at %s, error: %s", pth, revision, strings.TrimSpace(stderr.String()))
+	}
+	return content, nil
+}
+
+// mergeRepositoryBitriseYML merges the modules of the bitrise.yml committed at the given revision.
+func mergeRepositoryBitriseYML(searchDir, revision string) ([]byte, error) {
+	return mergeBitriseYML(bitriseYMLName, func(modulePth string) ([]byte, error) {
+		return gitShow(searchDir, revision, modulePth)
+	})
+}
@@ -0,0 +1,72 @@
+package phases
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	mainBitriseYML = `format_version: "13"
+project_type: other
+include:
+- path: modules/workflows.yml
+app:
+  envs:
+  - APP_ENV: main
+`
+	workflowsModule = `workflows:
+  primary:
+    steps:
+    - git-clone@8: {}
+`
+)
+
+func writeModularBitriseYML(t *testing.T, dir string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "modules"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, bitriseYMLName), []byte(mainBitriseYML), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "modules", "workflows.yml"), []byte(workflowsModule), 0644))
+}
+
+func Test_mergeLocalBitriseYML(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ci")
+	writeModularBitriseYML(t, dir)
+
+	require.True(t, isModularBitriseYML([]byte(mainBitriseYML)))
+	require.False(t, isModularBitriseYML([]byte(workflowsModule)))
+
+	merged, err := mergeLocalBitriseYML(filepath.Join(dir, bitriseYMLName))
+	require.NoError(t, err)
+
+	bitriseYML, _, err := ParseBitriseYMLFile(bytes.NewReader(merged))
+	require.NoError(t, err)
+	require.Contains(t, bitriseYML.Workflows, "primary")
+	require.Equal(t, "other", bitriseYML.ProjectType)
+	require.Len(t, bitriseYML.App.Environments, 1)
+}
+
+func Test_mergeRepositoryBitriseYML(t *testing.T) {
+	dir, repo := initTestRepo(t)
+	writeModularBitriseYML(t, dir)
+
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = worktree.Add(".")
+	require.NoError(t, err)
+	_, err = worktree.Commit("Add modular bitrise.yml", &git.CommitOptions{})
+	require.NoError(t, err)
+
+	// uncommitted changes of the modules are not used
+	require.NoError(t, os.Remove(filepath.Join(dir, "modules", "workflows.yml")))
+
+	merged, err := mergeRepositoryBitriseYML(dir, "HEAD")
+	require.NoError(t, err)
+
+	bitriseYML, _, err := ParseBitriseYMLFile(bytes.NewReader(merged))
+	require.NoError(t, err)
+	require.Contains(t, bitriseYML.Workflows, "primary")
+}
@@ -0,0 +1,201 @@
+package phases
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/manifoldco/promptui"
+)
+
+func pagerCommand() []string {
+	if pager := os.Getenv("PAGER"); pager != "" {
+		return strings.Fields(pager)
+	}
+	// Quit if the content fits on one screen and keep it on the terminal after quitting.
+	return []string{"less", "-FRX"}
+}
+
+func editorCommand() []string {
+	for _, key := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(key); editor != "" {
+			return strings.Fields(editor)
+		}
+	}
+	return []string{"vi"}
+}
+
+// showBitriseYML pages the bitrise.yml content, or prints it if no pager is available.
+func showBitriseYML(content []byte) {
+	cmd, err := command.NewFromSlice(pagerCommand())
+	if err == nil {
+		cmd.SetStdin(bytes.NewReader(content)).SetStdout(os.Stdout).SetStderr(os.Stderr)
+		if err = cmd.Run(); err == nil {
+			return
+		}
+	}
+	log.Debugf("Failed to run pager, error: %s", err)
+
+	fmt.Println()
+	fmt.Print(string(content))
+	fmt.Println()
+}
+
+// editBitriseYML opens the bitrise.yml content in the user's editor and returns the edited content.
+func editBitriseYML(content []byte) ([]byte, error) {
+	tmpDir, err := os.MkdirTemp("", "bitrise-yml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory, error: %s", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Warnf("Failed to remove temporary directory (%s), error: %s", tmpDir, err)
+		}
+	}()
+
+	pth := filepath.Join(tmpDir, bitriseYMLName)
+	if err := os.WriteFile(pth, content, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write bitrise.yml, error: %s", err)
+	}
+
+	editor := editorCommand()
+	cmd, err := command.NewFromSlice(append(editor, pth))
+	if err != nil {
+		return nil, err
+	}
+	cmd.SetStdin(os.Stdin).SetStdout(os.Stdout).SetStderr(os.Stderr)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run editor (%s), error: %s", strings.Join(editor, " "), err)
+	}
+
+	return os.ReadFile(pth)
+}
+
+// validateEditedBitriseYML parses and lints the edited bitrise.yml, then applies the selected stack and machine type to it.
+// The project type, stack and machine type are selected in their own steps (the stack and machine type are validated against the account),
+// so changing them in the editor is refused.
+func validateEditedBitriseYML(progress Progress, content []byte) (models.BitriseDataModel, []byte, error) {
+	bitriseYML, warnings, err := ParseBitriseYMLFile(bytes.NewReader(content))
+	if err != nil {
+		return models.BitriseDataModel{}, nil, err
+	}
+	for _, warning := range warnings {
+		log.Warnf(warning)
+	}
+
+	lint := LintBitriseYML(bitriseYML, progress.ProjectType)
+	if err := validateBuildTarget(bitriseYML, progress.BuildTarget); err != nil {
+		lint.errorf("%s", err)
+	}
+	if bitriseYML.ProjectType != "" && bitriseYML.ProjectType != progress.ProjectType {
+		lint.errorf("%s can not be changed here (selected: %s, edited: %s), use the --project-type flag to select another one", projectTypeKey, progress.ProjectType, bitriseYML.ProjectType)
+	}
+	for _, meta := range []struct{ key, selected, flag string }{
+		{"stack", progress.Stack, "stack"},
+		{"machine_type_id", progress.MachineType, "machine-type"},
+	} {
+		if edited := BitriseIOMeta(bitriseYML, meta.key); edited != "" && edited != meta.selected {
+			lint.errorf("%s.%s.%s can not be changed here (selected: %s, edited: %s), use the --%s flag to select another one", metaKey, bitriseIOKey, meta.key, meta.selected, edited, meta.flag)
+		}
+	}
+	if err := lint.Err(); err != nil {
+		return models.BitriseDataModel{}, nil, err
+	}
+	if len(lint.Warnings) > 0 {
+		log.Warnf("The bitrise.yml has warnings:")
+		logLintResult(lint)
+	}
+
+	SetStack(&bitriseYML, progress.Stack)
+	if progress.MachineType != "" {
+		SetBitriseIOMeta(&bitriseYML, "machine_type_id", progress.MachineType)
+	}
+
+	progress.BitriseYML = bitriseYML
+	progress.BitriseYMLContent = content
+	content, err = bitriseYMLToUpload(progress)
+	if err != nil {
+		return models.BitriseDataModel{}, nil, err
+	}
+	return bitriseYML, content, nil
+}
+
+// PreviewBitriseYML shows the bitrise.yml to be uploaded and lets the user edit it,
+// returns the accepted bitrise.yml and its content.
+func PreviewBitriseYML(progress Progress) (models.BitriseDataModel, []byte, error) {
+	fmt.Println()
+	log.Infof("REVIEW BITRISE.YML")
+
+	content, err := bitriseYMLToUpload(progress)
+	if err != nil {
+		return models.BitriseDataModel{}, nil, err
+	}
+	bitriseYML := progress.BitriseYML
+	showBitriseYML(content)
+
+	optionUpload := "Upload this bitrise.yml"
+	optionShow := "Show the bitrise.yml again"
+	optionEdit := fmt.Sprintf("Edit the bitrise.yml (%s)", strings.Join(editorCommand(), " "))
+	optionEditAgain := "Edit the bitrise.yml again"
+	optionDiscard := "Discard the changes"
+
+	// invalid is the last edited version which failed to validate
+	var invalid []byte
+	for {
+		items := []string{optionUpload, optionShow, optionEdit}
+		if invalid != nil {
+			items = []string{optionEditAgain, optionDiscard}
+		}
+
+		prompt := promptui.Select{
+			Label: "Review the bitrise.yml",
+			Items: items,
+			Templates: &promptui.SelectTemplates{
+				Label:    fmt.Sprintf("%s {{.}} ", promptui.IconInitial),
+				Selected: "{{ . | green }}",
+			},
+		}
+
+		_, answer, err := prompt.Run()
+		if err != nil {
+			return models.BitriseDataModel{}, nil, fmt.Errorf("scan user input: %s", err)
+		}
+
+		switch answer {
+		case optionUpload:
+			return bitriseYML, content, nil
+		case optionShow:
+			showBitriseYML(content)
+		case optionDiscard:
+			invalid = nil
+			log.Printf("Changes discarded.")
+		case optionEdit, optionEditAgain:
+			toEdit := content
+			if invalid != nil {
+				toEdit = invalid
+			}
+
+			edited, err := editBitriseYML(toEdit)
+			if err != nil {
+				return models.BitriseDataModel{}, nil, err
+			}
+
+			editedBitriseYML, editedContent, err := validateEditedBitriseYML(progress, edited)
+			if err != nil {
+				log.Errorf("The edited bitrise.yml is not valid: %s", err)
+				invalid = edited
+				continue
+			}
+
+			invalid = nil
+			bitriseYML, content = editedBitriseYML, editedContent
+			log.Donef("bitrise.yml updated.")
+			showBitriseYML(content)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package phases
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateEditedBitriseYML(t *testing.T) {
+	progress := Progress{
+		ProjectType: "other",
+		BuildTarget: BuildTarget{WorkflowID: "primary"},
+		Stack:       "linux-docker-android-22.04",
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		bitriseYML, content, err := validateEditedBitriseYML(progress, []byte(`format_version: "13"
+# edited
+workflows:
+  primary:
+    steps:
+    - git-clone@8: {}
+`))
+		require.NoError(t, err)
+		require.Equal(t, "linux-docker-android-22.04", BitriseIOMeta(bitriseYML, "stack"))
+		require.Equal(t, `format_version: "13"
+# edited
+workflows:
+  primary:
+    steps:
+    - git-clone@8: {}
+meta:
+  bitrise.io:
+    stack: linux-docker-android-22.04
+`, string(content))
+	})
+
+	t.Run("changed stack", func(t *testing.T) {
+		_, _, err := validateEditedBitriseYML(progress, []byte(`format_version: "13"
+workflows:
+  primary:
+    steps:
+    - git-clone@8: {}
+meta:
+  bitrise.io:
+    stack: osx-xcode-16.0.x
+`))
+		require.EqualError(t, err, "bitrise.yml is not valid:\n- meta.bitrise.io.stack can not be changed here (selected: linux-docker-android-22.04, edited: osx-xcode-16.0.x), use the --stack flag to select another one")
+	})
+
+	t.Run("changed project type", func(t *testing.T) {
+		_, _, err := validateEditedBitriseYML(progress, []byte(`format_version: "13"
+project_type: android
+workflows:
+  primary:
+    steps:
+    - git-clone@8: {}
+`))
+		require.EqualError(t, err, "bitrise.yml is not valid:\n- project_type can not be changed here (selected: other, edited: android), use the --project-type flag to select another one")
+	})
+
+	t.Run("invalid yaml", func(t *testing.T) {
+		_, _, err := validateEditedBitriseYML(progress, []byte("workflows: [\n"))
+		require.Error(t, err)
+	})
+
+	t.Run("missing primary workflow", func(t *testing.T) {
+		_, _, err := validateEditedBitriseYML(progress, []byte(`format_version: "13"
+workflows:
+  deploy:
+    steps:
+    - git-clone@8: {}
+`))
+		require.EqualError(t, err, "bitrise.yml is not valid:\n- the workflow of the first build (primary) does not exist")
+	})
+}
@@ -0,0 +1,159 @@
+package phases
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/manifoldco/promptui"
+)
+
+// BuildTarget is the pipeline or the workflow run by the first build, only one of them is set.
+type BuildTarget struct {
+	PipelineID string
+	WorkflowID string
+}
+
+// String ...
+func (t BuildTarget) String() string {
+	if t.PipelineID != "" {
+		return "pipeline " + t.PipelineID
+	}
+	return "workflow " + t.WorkflowID
+}
+
+// isUtilityWorkflow reports whether the workflow can only be run as part of other workflows.
+func isUtilityWorkflow(workflowID string) bool {
+	return strings.HasPrefix(workflowID, "_")
+}
+
+// validateBuildTarget checks that the build target exists in the bitrise.yml and can be triggered.
+func validateBuildTarget(bitriseYML models.BitriseDataModel, target BuildTarget) error {
+	if target.PipelineID != "" {
+		if _, ok := bitriseYML.Pipelines[target.PipelineID]; !ok {
+			return fmt.Errorf("the pipeline of the first build (%s) does not exist", target.PipelineID)
+		}
+		return nil
+	}
+	if _, ok := bitriseYML.Workflows[target.WorkflowID]; !ok {
+		return fmt.Errorf("the workflow of the first build (%s) does not exist", target.WorkflowID)
+	}
+	if isUtilityWorkflow(target.WorkflowID) {
+		return fmt.Errorf("the workflow of the first build (%s) is a utility workflow, it can not be triggered", target.WorkflowID)
+	}
+	return nil
+}
+
+// pipelineWorkflowIDs returns the workflows run by the pipeline.
+func pipelineWorkflowIDs(bitriseYML models.BitriseDataModel, pipelineID string) []string {
+	pipeline := bitriseYML.Pipelines[pipelineID]
+
+	var ids []string
+	for _, stageItem := range pipeline.Stages {
+		for stageID := range stageItem {
+			for _, workflowItem := range bitriseYML.Stages[stageID].Workflows {
+				for workflowID := range workflowItem {
+					ids = append(ids, workflowID)
+				}
+			}
+		}
+	}
+
+	var graphIDs []string
+	for id, workflow := range pipeline.Workflows {
+		if workflow.Uses != "" {
+			id = workflow.Uses
+		}
+		graphIDs = append(graphIDs, id)
+	}
+	sort.Strings(graphIDs)
+
+	return append(ids, graphIDs...)
+}
+
+// BuildTargetStepIDs returns the IDs of the steps run by the pipeline or workflow.
+func BuildTargetStepIDs(bitriseYML models.BitriseDataModel, target BuildTarget) []string {
+	if target.PipelineID == "" {
+		return WorkflowStepIDs(bitriseYML, target.WorkflowID)
+	}
+
+	var ids []string
+	for _, workflowID := range pipelineWorkflowIDs(bitriseYML, target.PipelineID) {
+		ids = append(ids, WorkflowStepIDs(bitriseYML, workflowID)...)
+	}
+	return ids
+}
+
+type buildTargetItem struct {
+	BuildTarget
+	Label string
+}
+
+func buildTargetItems(bitriseYML models.BitriseDataModel) []buildTargetItem {
+	var items, utilityItems []buildTargetItem
+	for _, id := range sortedPipelineIDs(bitriseYML) {
+		items = append(items, buildTargetItem{BuildTarget: BuildTarget{PipelineID: id}, Label: "Pipeline: " + id})
+	}
+	for _, id := range sortedWorkflowIDs(bitriseYML) {
+		if isUtilityWorkflow(id) {
+			utilityItems = append(utilityItems, buildTargetItem{BuildTarget: BuildTarget{WorkflowID: id}, Label: fmt.Sprintf("Workflow: %s (utility workflow, can not be triggered)", id)})
+			continue
+		}
+		items = append(items, buildTargetItem{BuildTarget: BuildTarget{WorkflowID: id}, Label: "Workflow: " + id})
+	}
+	return append(items, utilityItems...)
+}
+
+// selectBuildTarget returns the pipeline or workflow to run in the first build.
+func selectBuildTarget(bitriseYML models.BitriseDataModel, inputReader io.Reader) (BuildTarget, error) {
+	if len(bitriseYML.Workflows) == 0 {
+		return BuildTarget{}, fmt.Errorf("no workflows found in bitrise.yml")
+	}
+
+	const defaultWorkflowName = "primary"
+	if _, contains := bitriseYML.Workflows[defaultWorkflowName]; contains && len(bitriseYML.Pipelines) == 0 {
+		return BuildTarget{WorkflowID: defaultWorkflowName}, nil
+	}
+
+	items := buildTargetItems(bitriseYML)
+	var triggerable []buildTargetItem
+	for _, item := range items {
+		if !isUtilityWorkflow(item.WorkflowID) {
+			triggerable = append(triggerable, item)
+		}
+	}
+	if len(triggerable) == 0 {
+		return BuildTarget{}, fmt.Errorf("no pipelines or workflows which can be triggered found in bitrise.yml")
+	}
+
+	if len(triggerable) == 1 {
+		log.Infof("Selecting %s", triggerable[0].BuildTarget)
+		return triggerable[0].BuildTarget, nil
+	}
+
+	for {
+		prompt := promptui.Select{
+			Label: "Select pipeline or workflow to run in the first build",
+			Items: items,
+			Templates: &promptui.SelectTemplates{
+				Active:   fmt.Sprintf("%s {{ .Label | cyan }}", promptui.IconSelect),
+				Inactive: "  {{ .Label }}",
+				Selected: "Selected: {{ .Label }}",
+			},
+		}
+
+		i, _, err := prompt.Run()
+		if err != nil {
+			return BuildTarget{}, err
+		}
+
+		if isUtilityWorkflow(items[i].WorkflowID) {
+			log.Warnf("Utility workflows can only run as part of other workflows, select a pipeline or another workflow.")
+			continue
+		}
+		return items[i].BuildTarget, nil
+	}
+}
@@ -0,0 +1,77 @@
+package phases
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const pipelinesBitriseYML = `pipelines:
+  ci:
+    workflows:
+      test: {}
+      build:
+        depends_on:
+        - test
+  release:
+    stages:
+    - deploy_stage: {}
+stages:
+  deploy_stage:
+    workflows:
+    - deploy: {}
+workflows:
+  _setup:
+    steps:
+    - git-clone@8: {}
+  build:
+    before_run:
+    - _setup
+    steps:
+    - xcode-archive@5: {}
+  deploy:
+    steps:
+    - deploy-to-bitrise-io@2: {}
+  test:
+    steps:
+    - xcode-test@5: {}
+`
+
+func Test_buildTargetItems(t *testing.T) {
+	var bitriseYML models.BitriseDataModel
+	require.NoError(t, yaml.Unmarshal([]byte(pipelinesBitriseYML), &bitriseYML))
+
+	var labels []string
+	for _, item := range buildTargetItems(bitriseYML) {
+		labels = append(labels, item.Label)
+	}
+	require.Equal(t, []string{
+		"Pipeline: ci",
+		"Pipeline: release",
+		"Workflow: build",
+		"Workflow: deploy",
+		"Workflow: test",
+		"Workflow: _setup (utility workflow, can not be triggered)",
+	}, labels)
+}
+
+func TestBuildTargetStepIDs(t *testing.T) {
+	var bitriseYML models.BitriseDataModel
+	require.NoError(t, yaml.Unmarshal([]byte(pipelinesBitriseYML), &bitriseYML))
+
+	require.Equal(t, []string{"git-clone", "xcode-archive", "xcode-test"}, BuildTargetStepIDs(bitriseYML, BuildTarget{PipelineID: "ci"}))
+	require.Equal(t, []string{"deploy-to-bitrise-io"}, BuildTargetStepIDs(bitriseYML, BuildTarget{PipelineID: "release"}))
+	require.Equal(t, []string{"xcode-test"}, BuildTargetStepIDs(bitriseYML, BuildTarget{WorkflowID: "test"}))
+}
+
+func Test_validateBuildTarget(t *testing.T) {
+	var bitriseYML models.BitriseDataModel
+	require.NoError(t, yaml.Unmarshal([]byte(pipelinesBitriseYML), &bitriseYML))
+
+	require.NoError(t, validateBuildTarget(bitriseYML, BuildTarget{PipelineID: "ci"}))
+	require.NoError(t, validateBuildTarget(bitriseYML, BuildTarget{WorkflowID: "build"}))
+	require.EqualError(t, validateBuildTarget(bitriseYML, BuildTarget{PipelineID: "nightly"}), "the pipeline of the first build (nightly) does not exist")
+	require.EqualError(t, validateBuildTarget(bitriseYML, BuildTarget{WorkflowID: "_setup"}), "the workflow of the first build (_setup) is a utility workflow, it can not be triggered")
+}
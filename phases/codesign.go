@@ -6,13 +6,13 @@ import (
 	"os"
 	"runtime"
 
+	"github.com/bitrise-io/bitrise-add-new-project/bitriseio"
 	bitriseModels "github.com/bitrise-io/bitrise/v2/models"
 	"github.com/bitrise-io/codesigndoc/models"
 	"github.com/bitrise-io/go-utils/command"
 	"github.com/bitrise-io/go-utils/errorutil"
 	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-io/go-utils/pathutil"
-	"github.com/bitrise-io/go-utils/sliceutil"
 	"github.com/manifoldco/promptui"
 )
 
@@ -33,19 +33,26 @@ type CodesignResult struct {
 	IOS     CodesignResultsIOS
 }
 
-// Project types "web", "macos" are unsuppored as do not have ios and android native projects
-// Project types "flutter", "cordova", "ionic", "react-native" are unsupported, due to ucertainty that native (Xcode or Android) project is present
+// Codesigning files are only exported if the native (Xcode or Android) project is surely present,
+// see the IOSCodesign and AndroidCodesign properties of the project types.
 
-var unknownPlatforms = []string{"", "other"}
+// codesignProjectType returns the description of the project type, a missing project type is the generic one.
+// Unknown project types are not looked up as the generic one, their codesigning files are not exported.
+func codesignProjectType(projectType string) (bitriseio.ProjectType, bool) {
+	if projectType == "" {
+		projectType = bitriseio.OtherProjectType
+	}
+	return bitriseio.LookupProjectType(projectType)
+}
 
 func isIOSCodesign(projectType string) bool {
-	return projectType == "ios" ||
-		sliceutil.IsStringInSlice(projectType, unknownPlatforms)
+	config, ok := codesignProjectType(projectType)
+	return ok && config.IOSCodesign
 }
 
 func isAndroidCodesign(projectType string) bool {
-	return projectType == "android" ||
-		sliceutil.IsStringInSlice(projectType, unknownPlatforms)
+	config, ok := codesignProjectType(projectType)
+	return ok && config.AndroidCodesign
 }
 
 // AutoCodesign ...
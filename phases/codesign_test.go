@@ -0,0 +1,28 @@
+package phases
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_isCodesign(t *testing.T) {
+	tests := []struct {
+		projectType string
+		ios         bool
+		android     bool
+	}{
+		{projectType: "ios", ios: true},
+		{projectType: "android", android: true},
+		{projectType: "flutter"},
+		{projectType: "other", ios: true, android: true},
+		{projectType: "", ios: true, android: true},
+		{projectType: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.projectType, func(t *testing.T) {
+			require.Equal(t, tt.ios, isIOSCodesign(tt.projectType))
+			require.Equal(t, tt.android, isAndroidCodesign(tt.projectType))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package phases
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// envVar is a KEY=VALUE pair of a dotenv file.
+type envVar struct {
+	Key   string
+	Value string
+}
+
+// parseDotenv parses the KEY=VALUE lines of a dotenv file, in the order of the file.
+// Empty lines and # comments are skipped, an optional export prefix is allowed,
+// double quoted values are unescaped, single quoted values are kept as is.
+func parseDotenv(content []byte) ([]envVar, error) {
+	var envVars []envVar
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !found || !envKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid line %d, expected format: KEY=VALUE", lineNum)
+		}
+
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`):
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted value in line %d: %s", lineNum, err)
+			}
+			value = unquoted
+		case len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'"):
+			value = value[1 : len(value)-1]
+		default:
+			if i := strings.Index(value, " #"); i != -1 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+
+		envVars = append(envVars, envVar{Key: key, Value: value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return envVars, nil
+}
+
+// readDotenv reads the env vars of the dotenv file.
+func readDotenv(pth string) ([]envVar, error) {
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file (%s), error: %s", pth, err)
+	}
+	envVars, err := parseDotenv(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file (%s): %s", pth, err)
+	}
+	return envVars, nil
+}
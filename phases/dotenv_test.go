@@ -0,0 +1,31 @@
+package phases
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseDotenv(t *testing.T) {
+	content := `# secrets
+SLACK_WEBHOOK=https://hooks.slack.com/services/T0/B0/X # team channel
+
+export GOOGLE_PLAY_JSON="{\"type\": \"service_account\"}\n"
+PASSWORD='p#ss word'
+EMPTY=
+`
+	envVars, err := parseDotenv([]byte(content))
+	require.NoError(t, err)
+	require.Equal(t, []envVar{
+		{Key: "SLACK_WEBHOOK", Value: "https://hooks.slack.com/services/T0/B0/X"},
+		{Key: "GOOGLE_PLAY_JSON", Value: "{\"type\": \"service_account\"}\n"},
+		{Key: "PASSWORD", Value: "p#ss word"},
+		{Key: "EMPTY", Value: ""},
+	}, envVars)
+
+	_, err = parseDotenv([]byte("VALID=1\nnot a pair\n"))
+	require.EqualError(t, err, "invalid line 2, expected format: KEY=VALUE")
+
+	_, err = parseDotenv([]byte("1KEY=value\n"))
+	require.EqualError(t, err, "invalid line 1, expected format: KEY=VALUE")
+}
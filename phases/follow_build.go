@@ -0,0 +1,158 @@
+package phases
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/bitrise-io/bitrise-add-new-project/bitriseio"
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// buildPollInterval is the time between two build status requests.
+const buildPollInterval = 5 * time.Second
+
+// buildFollowTimeout is the time after which following the build is given up, longer than the builds' time limit.
+const buildFollowTimeout = 3 * time.Hour
+
+// maxBuildStatusRetries is the number of consecutive failed build status requests after which following the build is given up,
+// the wait before the next request is doubled after each failure.
+const maxBuildStatusRetries = 5
+
+// buildLogStreamer writes the log chunks of the followed build which were not written yet.
+type buildLogStreamer struct {
+	out io.Writer
+	// next is the position of the next chunk to write
+	next int
+	// written is the length of the written log, as long as no chunk was missed
+	written int
+	// missed is set if chunks were rotated out of the listed ones between two polls
+	missed bool
+}
+
+func (s *buildLogStreamer) writeChunks(chunks []bitriseio.BuildLogChunk) error {
+	sorted := append([]bitriseio.BuildLogChunk{}, chunks...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Position < sorted[j].Position })
+
+	for _, chunk := range sorted {
+		if chunk.Position < s.next {
+			continue
+		}
+		if chunk.Position > s.next {
+			s.missed = true
+		}
+		if _, err := io.WriteString(s.out, chunk.Chunk); err != nil {
+			return err
+		}
+		s.next = chunk.Position + 1
+		s.written += len(chunk.Chunk)
+	}
+	return nil
+}
+
+// writeRemainder writes the end of the archived log, which was not listed in chunks before the build finished.
+func (s *buildLogStreamer) writeRemainder(rawLog []byte) error {
+	if s.missed || len(rawLog) <= s.written {
+		return nil
+	}
+	_, err := s.out.Write(rawLog[s.written:])
+	return err
+}
+
+// buildService is the part of the bitrise.io API used to follow a build.
+type buildService interface {
+	Build(buildSlug string) (*bitriseio.Build, error)
+	BuildLog(buildSlug string) (*bitriseio.BuildLog, error)
+	DownloadBuildLog(rawLogURL string) ([]byte, error)
+}
+
+// streamBuildLog writes the new log chunks of the build, failing to get the log does not stop following the build.
+func streamBuildLog(service buildService, buildSlug string, streamer *buildLogStreamer) *bitriseio.BuildLog {
+	buildLog, err := service.BuildLog(buildSlug)
+	if err != nil {
+		log.Debugf("Failed to get build log: %s", err)
+		return nil
+	}
+	if err := streamer.writeChunks(buildLog.LogChunks); err != nil {
+		log.Debugf("Failed to write build log: %s", err)
+	}
+	return buildLog
+}
+
+// followBuild polls the build until it finishes or the timeout elapses, transient errors are retried with backoff.
+func followBuild(service buildService, buildSlug string, streamer *buildLogStreamer, interval, timeout time.Duration) (*bitriseio.Build, error) {
+	deadline := time.Now().Add(timeout)
+	failures := 0
+	for {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("the build did not finish in %s", timeout)
+		}
+
+		build, err := service.Build(buildSlug)
+		if err != nil {
+			failures++
+			if failures > maxBuildStatusRetries {
+				return nil, fmt.Errorf("failed to get build status, error: %s", err)
+			}
+			log.Debugf("Failed to get build status (attempt %d): %s", failures, err)
+			time.Sleep(interval << failures)
+			continue
+		}
+		failures = 0
+
+		buildLog := streamBuildLog(service, buildSlug, streamer)
+		if !build.IsFinished() {
+			time.Sleep(interval)
+			continue
+		}
+
+		if buildLog != nil && buildLog.IsArchived && buildLog.ExpiringRawLogURL != "" {
+			rawLog, err := service.DownloadBuildLog(buildLog.ExpiringRawLogURL)
+			if err != nil {
+				log.Debugf("Failed to download build log: %s", err)
+			} else if err := streamer.writeRemainder(rawLog); err != nil {
+				log.Debugf("Failed to write build log: %s", err)
+			}
+		}
+		return build, nil
+	}
+}
+
+// FollowBuild polls the status of the first build until it finishes, streaming its log to the terminal.
+// It returns whether the build succeeded, pipelines can not be followed so those return an error.
+func FollowBuild(token, appSlug string, build bitriseio.TriggerBuildResponse) (bool, error) {
+	if build.BuildSlug == "" {
+		// Triggering a pipeline returns no build slug.
+		return false, fmt.Errorf("following pipelines is not supported, check the result at: https://app.bitrise.io/app/%s", appSlug)
+	}
+
+	fmt.Println()
+	log.Infof("FOLLOWING BUILD #%d", build.BuildNumber)
+	log.Printf("Build URL: %s", build.BuildURL)
+	fmt.Println()
+
+	client, err := bitriseio.NewClient(token)
+	if err != nil {
+		return false, err
+	}
+
+	streamer := &buildLogStreamer{out: os.Stdout}
+	finished, err := followBuild(client.Apps.App(appSlug), build.BuildSlug, streamer, buildPollInterval, buildFollowTimeout)
+	if err != nil {
+		return false, err
+	}
+	if streamer.missed {
+		log.Warnf("Parts of the log were skipped, see the full log at: %s", build.BuildURL)
+	}
+
+	fmt.Println()
+	if finished.IsSuccessful() {
+		log.Donef("Build #%d finished: %s", finished.BuildNumber, colorstring.Green(finished.StatusText))
+		return true, nil
+	}
+	log.Errorf("Build #%d finished: %s", finished.BuildNumber, finished.StatusText)
+	return false, nil
+}
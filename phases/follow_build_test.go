@@ -0,0 +1,95 @@
+package phases
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/bitrise-add-new-project/bitriseio"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBuildService struct {
+	builds []bitriseio.Build
+	logs   []bitriseio.BuildLog
+	rawLog string
+	polls  int
+	// failures is the number of build status requests failing before the builds are returned
+	failures int
+}
+
+func (s *fakeBuildService) Build(string) (*bitriseio.Build, error) {
+	if s.failures > 0 {
+		s.failures--
+		return nil, errors.New("502 Bad Gateway")
+	}
+	// the last build is returned once the polls are over
+	build := s.builds[min(s.polls, len(s.builds)-1)]
+	return &build, nil
+}
+
+func (s *fakeBuildService) BuildLog(string) (*bitriseio.BuildLog, error) {
+	var buildLog bitriseio.BuildLog
+	if s.polls < len(s.logs) {
+		buildLog = s.logs[s.polls]
+	}
+	s.polls++
+	return &buildLog, nil
+}
+
+func (s *fakeBuildService) DownloadBuildLog(string) ([]byte, error) {
+	return []byte(s.rawLog), nil
+}
+
+func Test_buildLogStreamer_writeChunks(t *testing.T) {
+	var out bytes.Buffer
+	streamer := &buildLogStreamer{out: &out}
+
+	require.NoError(t, streamer.writeChunks([]bitriseio.BuildLogChunk{{Chunk: "b", Position: 1}, {Chunk: "a", Position: 0}}))
+	require.NoError(t, streamer.writeChunks([]bitriseio.BuildLogChunk{{Chunk: "b", Position: 1}, {Chunk: "c", Position: 2}}))
+	require.Equal(t, "abc", out.String())
+	require.False(t, streamer.missed)
+
+	require.NoError(t, streamer.writeChunks([]bitriseio.BuildLogChunk{{Chunk: "e", Position: 4}}))
+	require.Equal(t, "abce", out.String())
+	require.True(t, streamer.missed)
+}
+
+func Test_followBuild(t *testing.T) {
+	service := &fakeBuildService{
+		builds: []bitriseio.Build{
+			{Status: bitriseio.BuildStatusRunning},
+			{Status: bitriseio.BuildStatusFailed, StatusText: "error"},
+		},
+		logs: []bitriseio.BuildLog{
+			{LogChunks: []bitriseio.BuildLogChunk{{Chunk: "git-clone\n", Position: 0}}},
+			{IsArchived: true, ExpiringRawLogURL: "https://example.com/log"},
+		},
+		rawLog:   "git-clone\nxcode-test\nfailed\n",
+		failures: maxBuildStatusRetries,
+	}
+
+	var out bytes.Buffer
+	build, err := followBuild(service, "build-slug", &buildLogStreamer{out: &out}, 0, time.Minute)
+	require.NoError(t, err)
+	require.False(t, build.IsSuccessful())
+	require.Equal(t, 2, service.polls)
+	require.Equal(t, "git-clone\nxcode-test\nfailed\n", out.String())
+}
+
+func Test_followBuild_errors(t *testing.T) {
+	service := &fakeBuildService{
+		builds:   []bitriseio.Build{{Status: bitriseio.BuildStatusRunning}},
+		failures: maxBuildStatusRetries + 1,
+	}
+	_, err := followBuild(service, "build-slug", &buildLogStreamer{out: &bytes.Buffer{}}, 0, time.Minute)
+	require.EqualError(t, err, "failed to get build status, error: 502 Bad Gateway")
+
+	service = &fakeBuildService{
+		builds: []bitriseio.Build{{Status: bitriseio.BuildStatusRunning}},
+	}
+	_, err = followBuild(service, "build-slug", &buildLogStreamer{out: &bytes.Buffer{}}, time.Millisecond, 10*time.Millisecond)
+	require.EqualError(t, err, "the build did not finish in 10ms")
+	require.Greater(t, service.polls, 1)
+}
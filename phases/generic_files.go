@@ -0,0 +1,287 @@
+package phases
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/manifoldco/promptui"
+)
+
+// GenericFile is a file uploaded to the Generic File Storage of the app,
+// the builds can download it from the URL in the BITRISEIO_<EnvKey>_URL env var.
+type GenericFile struct {
+	Path   string
+	EnvKey string
+}
+
+// Files commonly needed by the builds, but usually not committed.
+var genericFileEnvKeys = map[string]string{
+	"google-services.json":     "GOOGLE_SERVICES_JSON",
+	"GoogleService-Info.plist": "GOOGLE_SERVICE_INFO_PLIST",
+}
+
+// serviceAccountEnvKey is the key used by the Google Play Deploy step's default input.
+const serviceAccountEnvKey = "SERVICE_ACCOUNT_JSON_KEY"
+
+// Service account JSON keys are only searched among small files.
+const maxServiceAccountFileSize = 64 * 1024
+
+// Directories of dependencies and build outputs, not searched for generic files.
+var genericFileSkippedDirs = []string{".git", "node_modules", "Pods", "Carthage", ".gradle", "build", "DerivedData", ".dart_tool"}
+
+var nonEnvKeyCharPattern = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// genericFileEnvKey returns the suggested env key of the file: the known key of the file or its name in upper snake case.
+func genericFileEnvKey(pth string) string {
+	name := filepath.Base(pth)
+	if key, ok := genericFileEnvKeys[name]; ok {
+		return key
+	}
+	if isServiceAccountKey(pth) {
+		return serviceAccountEnvKey
+	}
+	return strings.Trim(nonEnvKeyCharPattern.ReplaceAllString(strings.ToUpper(name), "_"), "_")
+}
+
+// isServiceAccountKey reports whether the file is a Google service account JSON key (e.g. for the Play Console).
+func isServiceAccountKey(pth string) bool {
+	if filepath.Ext(pth) != ".json" {
+		return false
+	}
+	info, err := os.Stat(pth)
+	if err != nil || info.Size() > maxServiceAccountFileSize {
+		return false
+	}
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		return false
+	}
+	var key struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(content, &key) == nil && key.Type == "service_account"
+}
+
+// findGenericFiles returns the paths (relative to the search dir) of the generic file candidates.
+func findGenericFiles(searchDir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(searchDir, func(pth string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			for _, skipped := range genericFileSkippedDirs {
+				if d.Name() == skipped {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+
+		if _, ok := genericFileEnvKeys[d.Name()]; ok || isServiceAccountKey(pth) {
+			relPth, err := filepath.Rel(searchDir, pth)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, filepath.ToSlash(relPth))
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// gitignoreMatcher returns the matcher of the .gitignore files of the repository.
+func gitignoreMatcher(searchDir string) (gitignore.Matcher, error) {
+	patterns, err := gitignore.ReadPatterns(osfs.New(searchDir), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .gitignore files, error: %s", err)
+	}
+	return gitignore.NewMatcher(patterns), nil
+}
+
+// ignoredGenericFileNames returns the known generic files listed in the root .gitignore, they are missing from the builds' clones.
+func ignoredGenericFileNames(searchDir string) ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(searchDir, ".gitignore"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		name := path.Base(line)
+		if _, ok := genericFileEnvKeys[name]; ok && !sliceutil.IsStringInSlice(name, names) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, scanner.Err()
+}
+
+func askGenericFileEnvKey(defaultKey string) (string, error) {
+	prompt := promptui.Prompt{
+		Label:   "Env var key of the file (its download URL is exposed as BITRISEIO_<KEY>_URL)",
+		Default: defaultKey,
+		Validate: func(key string) error {
+			if !envKeyPattern.MatchString(strings.TrimSpace(key)) {
+				return errors.New("invalid env var key")
+			}
+			return nil
+		},
+	}
+
+	key, err := prompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("prompt user: %s", err)
+	}
+	return strings.TrimSpace(key), nil
+}
+
+func askGenericFilePath() (string, error) {
+	prompt := promptui.Prompt{
+		Label: "Enter the path of the file",
+		Validate: func(pth string) error {
+			absPth, err := pathutil.AbsPath(strings.TrimSpace(pth))
+			if err != nil {
+				return err
+			}
+			if info, err := os.Stat(absPth); err != nil || info.IsDir() {
+				return errors.New("file does not exist")
+			}
+			return nil
+		},
+	}
+
+	pth, err := prompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("prompt user: %s", err)
+	}
+	return pathutil.AbsPath(strings.TrimSpace(pth))
+}
+
+// askGenericFile asks for the env key of the file and returns the file to upload.
+func askGenericFile(pth string, files []GenericFile) (GenericFile, error) {
+	for {
+		key, err := askGenericFileEnvKey(genericFileEnvKey(pth))
+		if err != nil {
+			return GenericFile{}, err
+		}
+		for _, file := range files {
+			if file.EnvKey == key {
+				log.Warnf("The %s key is already used by %s, choose another one.", key, file.Path)
+				key = ""
+			}
+		}
+		if key != "" {
+			return GenericFile{Path: pth, EnvKey: key}, nil
+		}
+	}
+}
+
+// GenericFiles offers to upload the files the builds usually need, but which are not committed
+// (e.g. google-services.json, GoogleService-Info.plist or a Play Console service account key), to the Generic File Storage.
+func GenericFiles(searchDir string) ([]GenericFile, error) {
+	fmt.Println()
+	log.Infof("GENERIC FILE STORAGE")
+
+	paths, err := findGenericFiles(searchDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for files, error: %s", err)
+	}
+	matcher, err := gitignoreMatcher(searchDir)
+	if err != nil {
+		return nil, err
+	}
+	ignoredNames, err := ignoredGenericFileNames(searchDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .gitignore, error: %s", err)
+	}
+
+	var files []GenericFile
+	var foundNames []string
+	for _, pth := range paths {
+		foundNames = append(foundNames, path.Base(pth))
+		label := fmt.Sprintf("%s is ignored by git, so the builds will not have it. Do you want to upload it?", pth)
+		if !matcher.Match(strings.Split(pth, "/"), false) {
+			if !isServiceAccountKey(filepath.Join(searchDir, filepath.FromSlash(pth))) {
+				log.Printf("%s is not ignored by git, the builds can use it once it is committed.", pth)
+				continue
+			}
+			// The key grants access to the Play Console, it should not be committed.
+			log.Warnf("%s is a service account key, do not commit it: add it to .gitignore and upload it to the Generic File Storage instead.", pth)
+			label = fmt.Sprintf("Do you want to upload %s?", pth)
+		}
+		if !isInteractive() {
+			log.Warnf("%s is not uploaded, run the tool in a terminal to upload it to the Generic File Storage.", pth)
+			continue
+		}
+
+		upload, err := askYesNo(label, "Upload "+pth)
+		if err != nil {
+			return nil, err
+		}
+		if !upload {
+			continue
+		}
+
+		file, err := askGenericFile(filepath.Join(searchDir, filepath.FromSlash(pth)), files)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	var missingNames []string
+	for _, name := range ignoredNames {
+		if !sliceutil.IsStringInSlice(name, foundNames) {
+			log.Warnf("%s is listed in .gitignore, but not found in the repository, the builds will not have it.", name)
+			missingNames = append(missingNames, name)
+		}
+	}
+
+	// The files listed in .gitignore might be stored outside of the repository.
+	for len(missingNames) > 0 && isInteractive() {
+		upload, err := askYesNo(fmt.Sprintf("Do you want to upload another file to the Generic File Storage (%s)?", strings.Join(missingNames, ", ")), "Upload another file")
+		if err != nil {
+			return nil, err
+		}
+		if !upload {
+			return files, nil
+		}
+
+		pth, err := askGenericFilePath()
+		if err != nil {
+			return nil, err
+		}
+		file, err := askGenericFile(pth, files)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+		if i := sliceutil.IndexOfStringInSlice(filepath.Base(pth), missingNames); i != -1 {
+			missingNames = append(missingNames[:i], missingNames[i+1:]...)
+		}
+	}
+	return files, nil
+}
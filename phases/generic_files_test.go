@@ -0,0 +1,65 @@
+package phases
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_findGenericFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore":                        "google-services.json\n/ios/GoogleService-Info.plist\n# comment\nsecrets/\n",
+		"app/google-services.json":          `{"project_info": {}}`,
+		"secrets/play.json":                 `{"type": "service_account", "project_id": "app"}`,
+		"app/src/main/res/values.json":      `{"type": "other"}`,
+		"node_modules/google-services.json": `{}`,
+	}
+	for pth, content := range files {
+		pth = filepath.Join(dir, pth)
+		require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0700))
+		require.NoError(t, os.WriteFile(pth, []byte(content), 0600))
+	}
+
+	paths, err := findGenericFiles(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"app/google-services.json", "secrets/play.json"}, paths)
+
+	matcher, err := gitignoreMatcher(dir)
+	require.NoError(t, err)
+	require.True(t, matcher.Match([]string{"app", "google-services.json"}, false))
+	require.True(t, matcher.Match([]string{"secrets", "play.json"}, false))
+	require.False(t, matcher.Match([]string{"app", "src", "main", "res", "values.json"}, false))
+
+	names, err := ignoredGenericFileNames(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"GoogleService-Info.plist", "google-services.json"}, names)
+
+	require.Equal(t, "GOOGLE_SERVICES_JSON", genericFileEnvKey(filepath.Join(dir, "app", "google-services.json")))
+	require.Equal(t, serviceAccountEnvKey, genericFileEnvKey(filepath.Join(dir, "secrets", "play.json")))
+	require.Equal(t, "VALUES_JSON", genericFileEnvKey(filepath.Join(dir, "app", "src", "main", "res", "values.json")))
+}
+
+func TestGenericFiles_nonInteractive(t *testing.T) {
+	interactive := isInteractive
+	isInteractive = func() bool { return false }
+	defer func() { isInteractive = interactive }()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore":               "google-services.json\nGoogleService-Info.plist\n",
+		"app/google-services.json": `{"project_info": {}}`,
+		"play.json":                `{"type": "service_account", "project_id": "app"}`,
+	}
+	for pth, content := range files {
+		pth = filepath.Join(dir, pth)
+		require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0700))
+		require.NoError(t, os.WriteFile(pth, []byte(content), 0600))
+	}
+
+	uploaded, err := GenericFiles(dir)
+	require.NoError(t, err)
+	require.Empty(t, uploaded)
+}
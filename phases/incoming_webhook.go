@@ -0,0 +1,73 @@
+package phases
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/bitrise-io/bitrise-add-new-project/bitriseio"
+)
+
+const (
+	incomingWebhookBaseURL = "https://hooks.bitrise.io/h/"
+	buildTriggerURLFormat  = "https://app.bitrise.io/app/%s/build/start.json"
+)
+
+// webhookService returns the ID of the bitrise.io incoming webhook service of the git provider hosting the repository,
+// or an empty string if the provider is unknown. Self-hosted instances are recognized by their host name (e.g. gitlab.example.com).
+func webhookService(repoURL string) string {
+	u, err := parseURL(repoURL)
+	if err != nil {
+		return ""
+	}
+
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "bitbucket.org":
+		return "bitbucket-v2"
+	case strings.Contains(host, "bitbucket"):
+		return "bitbucket-server"
+	case strings.Contains(host, "github"):
+		return "github"
+	case strings.Contains(host, "gitlab"):
+		return "gitlab"
+	}
+	return ""
+}
+
+// incomingWebhookURL returns the URL of the incoming webhook to add to the repository's webhooks,
+// or an empty string if the git provider is unknown.
+func incomingWebhookURL(repoURL, appSlug, buildTriggerToken string) string {
+	service := webhookService(repoURL)
+	if service == "" || buildTriggerToken == "" {
+		return ""
+	}
+	return incomingWebhookBaseURL + strings.Join([]string{service, appSlug, url.PathEscape(buildTriggerToken)}, "/")
+}
+
+// buildTriggerCurl returns a curl command starting a build of the target with the build trigger token,
+// it can be run from any CI or script.
+func buildTriggerCurl(appSlug, buildTriggerToken string, buildParams bitriseio.TriggerBuildParams) (string, error) {
+	type HookInfo struct {
+		Type              string `json:"type"`
+		BuildTriggerToken string `json:"build_trigger_token"`
+	}
+	type Params struct {
+		HookInfo    HookInfo                     `json:"hook_info"`
+		BuildParams bitriseio.TriggerBuildParams `json:"build_params"`
+		TriggeredBy string                       `json:"triggered_by"`
+	}
+	data, err := json.Marshal(Params{
+		HookInfo:    HookInfo{Type: "bitrise", BuildTriggerToken: buildTriggerToken},
+		BuildParams: buildParams,
+		TriggeredBy: "curl",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// the data is single quoted for the shell
+	quoted := "'" + strings.ReplaceAll(string(data), "'", `'\''`) + "'"
+	return fmt.Sprintf("curl -X POST %s --data %s", fmt.Sprintf(buildTriggerURLFormat, appSlug), quoted), nil
+}
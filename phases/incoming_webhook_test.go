@@ -0,0 +1,56 @@
+package phases
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/bitrise-add-new-project/bitriseio"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_incomingWebhookURL(t *testing.T) {
+	tests := []struct {
+		repoURL string
+		want    string
+	}{
+		{repoURL: "git@github.com:bitrise-io/go-utils.git", want: "https://hooks.bitrise.io/h/github/app-slug/token"},
+		{repoURL: "https://gitlab.example.com/team/app.git", want: "https://hooks.bitrise.io/h/gitlab/app-slug/token"},
+		{repoURL: "https://bitbucket.org/team/app.git", want: "https://hooks.bitrise.io/h/bitbucket-v2/app-slug/token"},
+		{repoURL: "ssh://git@bitbucket.example.com:7999/team/app.git", want: "https://hooks.bitrise.io/h/bitbucket-server/app-slug/token"},
+		{repoURL: "https://git.example.com/team/app.git", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.repoURL, func(t *testing.T) {
+			require.Equal(t, tt.want, incomingWebhookURL(tt.repoURL, "app-slug", "token"))
+		})
+	}
+}
+
+func Test_buildTriggerCurl(t *testing.T) {
+	curl, err := buildTriggerCurl("app-slug", "token", bitriseio.TriggerBuildParams{WorkflowID: "primary", Branch: "it's-main"})
+	require.NoError(t, err)
+	require.Equal(t, `curl -X POST https://app.bitrise.io/app/app-slug/build/start.json --data '{"hook_info":{"type":"bitrise","build_trigger_token":"token"},"build_params":{"workflow_id":"primary","branch":"it'\''s-main"},"triggered_by":"curl"}'`, curl)
+}
+
+func TestWriteRegisterResult(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "result.json")
+	result := RegisterResult{
+		AppSlug:           "app-slug",
+		BuildTriggerToken: "token",
+		BuildTriggerCurl:  "curl",
+	}
+	require.NoError(t, WriteRegisterResult(pth, result))
+
+	content, err := os.ReadFile(pth)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	require.Equal(t, "token", decoded["build_trigger_token"])
+	require.NotContains(t, decoded, "incoming_webhook_url")
+
+	info, err := os.Stat(pth)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
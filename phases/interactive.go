@@ -0,0 +1,13 @@
+package phases
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// isInteractive reports whether the user can answer the prompts,
+// the standard input is not a terminal on CI or when the input is piped.
+var isInteractive = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
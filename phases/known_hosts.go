@@ -0,0 +1,51 @@
+package phases
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/bitrise-add-new-project/sshutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/manifoldco/promptui"
+)
+
+// KnownHostsSecretKey is the app secret holding the host keys confirmed during the registration in known_hosts format.
+const KnownHostsSecretKey = "SSH_KNOWN_HOSTS_ENTRIES"
+
+// UploadKnownHosts asks the user whether to store the host keys verified during the
+// repository access setup as an app secret. The builds do not read the secret on their own,
+// a step has to write it to ~/.ssh/known_hosts before git-clone to pin the same keys.
+func UploadKnownHosts(hostKeys *sshutil.KnownHosts) (bool, error) {
+	if hostKeys == nil || len(hostKeys.AcceptedHostKeys()) == 0 {
+		return false, nil
+	}
+
+	fmt.Println()
+	log.Infof("SSH HOST KEYS")
+	log.Printf("Host keys verified during the setup:")
+	for _, line := range hostKeys.AcceptedHostKeys() {
+		log.Printf("- %s", line)
+	}
+	log.Printf("The builds only pin these keys if a step before git-clone writes the %s secret to ~/.ssh/known_hosts, e.g. a script step running:", KnownHostsSecretKey)
+	log.Printf("  mkdir -p ~/.ssh && echo \"$%s\" >> ~/.ssh/known_hosts", KnownHostsSecretKey)
+
+	const (
+		optionYes = "Yes"
+		optionNo  = "No"
+	)
+
+	prompt := promptui.Select{
+		Label: fmt.Sprintf("Do you want to store these host keys in the %s app secret?", KnownHostsSecretKey),
+		Items: []string{optionYes, optionNo},
+		Templates: &promptui.SelectTemplates{
+			Label:    fmt.Sprintf("%s {{.}} ", promptui.IconInitial),
+			Selected: "Upload host keys: {{ . | green }}",
+		},
+	}
+
+	_, answer, err := prompt.Run()
+	if err != nil {
+		return false, fmt.Errorf("scan user input: %s", err)
+	}
+
+	return answer == optionYes, nil
+}
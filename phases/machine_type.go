@@ -0,0 +1,136 @@
+package phases
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/manifoldco/promptui"
+)
+
+type machineTypeData struct {
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	CPUCount        string  `json:"cpu_count"`
+	CPUDescription  string  `json:"cpu_description"`
+	RAM             string  `json:"ram"`
+	CreditPerMinute float64 `json:"credit_per_minute"`
+	IsDefault       bool    `json:"is_default"`
+}
+
+type machineTypesResponse struct {
+	Data []machineTypeData `json:"data"`
+}
+
+func (m machineTypeData) String() string {
+	s := m.ID
+	if m.Name != "" {
+		s += fmt.Sprintf(" (%s)", m.Name)
+	}
+	if m.CPUCount != "" && m.RAM != "" {
+		s += fmt.Sprintf(" - %s CPU, %s RAM", m.CPUCount, m.RAM)
+	}
+	if m.CreditPerMinute > 0 {
+		s += fmt.Sprintf(", %g credits/min", m.CreditPerMinute)
+	}
+	return s
+}
+
+func fetchMachineTypes(orgSlug, apiToken, stack string) ([]machineTypeData, error) {
+	var u string
+	if orgSlug != "" {
+		u = fmt.Sprintf("https://api.bitrise.io/v0.1/organizations/%s/machine_types", orgSlug)
+	} else {
+		u = "https://api.bitrise.io/v0.1/me/machine_types"
+	}
+	u += "?stack_id=" + url.QueryEscape(stack)
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "token "+apiToken)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			log.Debugf("Failed to close response body: %s", err)
+		}
+	}()
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("server response: %s", res.Status)
+	}
+
+	var machineTypes machineTypesResponse
+	if err := json.NewDecoder(res.Body).Decode(&machineTypes); err != nil {
+		return nil, err
+	}
+
+	return machineTypes.Data, nil
+}
+
+// MachineType returns the machine type selected for the given stack.
+// An empty machine type means the account's default machine is used.
+func MachineType(orgSlug, apiToken, stack, machineType string) (string, error) {
+	fmt.Println()
+	log.Infof("SELECT MACHINE TYPE")
+
+	machineTypes, err := fetchMachineTypes(orgSlug, apiToken, stack)
+	if err != nil {
+		if machineType != "" {
+			return "", fmt.Errorf("failed to fetch available machine types, error: %s", err)
+		}
+		// the machine type is optional, the registration continues with the account's default machine
+		log.Warnf("Failed to fetch available machine types, using the default machine: %s", err)
+		return "", nil
+	}
+
+	if machineType != "" {
+		for _, m := range machineTypes {
+			if m.ID == machineType {
+				log.Donef(colorstring.Greenf("Selected machine type: ") + m.String())
+				return machineType, nil
+			}
+		}
+		return "", fmt.Errorf("machine type (%s) is not available for the %s stack", machineType, stack)
+	}
+
+	if len(machineTypes) == 0 {
+		log.Printf("No machine types available to choose from for the %s stack, using the default machine.", stack)
+		return "", nil
+	}
+
+	var (
+		items     []string
+		cursorPos int
+	)
+	for i, m := range machineTypes {
+		items = append(items, m.String())
+		if m.IsDefault {
+			cursorPos = i
+		}
+	}
+
+	prompt := promptui.Select{
+		Label:     "Choose machine type",
+		Items:     items,
+		CursorPos: cursorPos,
+		Templates: &promptui.SelectTemplates{
+			Selected: "Machine type: {{ . | green }}",
+		},
+	}
+
+	i, _, err := prompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("scan user input: %s", err)
+	}
+
+	return machineTypes[i].ID, nil
+}
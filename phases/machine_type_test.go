@@ -0,0 +1,58 @@
+package phases
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// stubHTTPResponse makes the default HTTP client respond with the given status and body.
+func stubHTTPResponse(t *testing.T, statusCode int, body string) {
+	transport := http.DefaultClient.Transport
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+
+	http.DefaultClient.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: statusCode,
+			Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+}
+
+func TestMachineType(t *testing.T) {
+	const machineTypes = `{"data": [{"id": "g2.4core", "name": "Medium"}, {"id": "g2.8core", "name": "Large"}]}`
+
+	t.Run("flag", func(t *testing.T) {
+		stubHTTPResponse(t, http.StatusOK, machineTypes)
+
+		machineType, err := MachineType("", "token", "osx-xcode-16.0.x", "g2.8core")
+		require.NoError(t, err)
+		require.Equal(t, "g2.8core", machineType)
+
+		_, err = MachineType("", "token", "osx-xcode-16.0.x", "g2.12core")
+		require.EqualError(t, err, "machine type (g2.12core) is not available for the osx-xcode-16.0.x stack")
+	})
+
+	t.Run("fetch failure", func(t *testing.T) {
+		stubHTTPResponse(t, http.StatusInternalServerError, "")
+
+		_, err := MachineType("", "token", "osx-xcode-16.0.x", "g2.8core")
+		require.EqualError(t, err, "failed to fetch available machine types, error: server response: 500 Internal Server Error")
+
+		machineType, err := MachineType("", "token", "osx-xcode-16.0.x", "")
+		require.NoError(t, err)
+		require.Equal(t, "", machineType)
+	})
+}
@@ -56,7 +56,7 @@ func generateSSHKey() (sshutil.SSHKeyPair, error) {
 }
 
 // PrivateKey ...
-func PrivateKey(repoURL RepoDetails) (sshutil.SSHKeyPair, bool, error) {
+func PrivateKey(repoURL RepoDetails, searchDir string, hostKeys *sshutil.KnownHosts) (sshutil.SSHKeyPair, bool, error) {
 	fmt.Println()
 	log.Infof("SETUP REPOSITORY ACCESS")
 	log.Printf("For automatic ssh key registration git provider must be connected at: https://app.bitrise.io/me/profile")
@@ -117,11 +117,22 @@ func PrivateKey(repoURL RepoDetails) (sshutil.SSHKeyPair, bool, error) {
 		log.Warnf("Copy this SSH public key to your clipboard and add it to any additional Git repository or account!")
 		fmt.Println(string(SSHKeys.PublicKey))
 
+		repoURLs, err := additionalRepoURLs(searchDir, repoURL.URL)
+		if err != nil {
+			return SSHKeys, false, err
+		}
+
 		log.Printf("Hit enter if you have finished with the setup")
 		if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
 			return SSHKeys, false, fmt.Errorf("failed to read line from input, error: %s", err)
 		}
 
+		if len(repoURLs) > 0 {
+			if err := verifyAdditionalRepoAccess(SSHKeys, repoURLs, hostKeys); err != nil {
+				return SSHKeys, false, err
+			}
+		}
+
 		return SSHKeys, true, nil
 	}
 
@@ -149,7 +160,7 @@ func PrivateKey(repoURL RepoDetails) (sshutil.SSHKeyPair, bool, error) {
 		}
 
 		var valid bool
-		if valid, err = sshutil.ValidatePrivateKey(SSHKeys.PrivateKey, repoURL.SSHUsername, repoURL.URL); !valid {
+		if valid, err = sshutil.ValidatePrivateKey(SSHKeys.PrivateKey, repoURL.SSHUsername, repoURL.URL, hostKeys); !valid {
 			log.Errorf("Could not connect to repository with private key, error: %s", err)
 			return err
 		}
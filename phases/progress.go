@@ -12,17 +12,26 @@ type Progress struct {
 
 	RepoDetails RepoDetails
 
-	SSHKeys        sshutil.SSHKeyPair
-	RegisterSSHKey bool
+	SSHKeys          sshutil.SSHKeyPair
+	RegisterSSHKey   bool
+	KnownHosts       *sshutil.KnownHosts
+	UploadKnownHosts bool
 
-	BitriseYML      models.BitriseDataModel
-	PrimaryWorkflow string
-	Branch          string
-	ProjectType     string
+	BitriseYML        models.BitriseDataModel
+	BitriseYMLContent []byte
+	BitriseYMLSource  string
+	BuildTarget       BuildTarget
+	Branch            string
+	ProjectType       string
 
-	Stack string
+	Stack       string
+	MachineType string
+
+	Secrets []Secret
 
 	AddWebhook bool
 
 	Codesign CodesignResult
+
+	GenericFiles []GenericFile
 }
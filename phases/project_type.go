@@ -0,0 +1,90 @@
+package phases
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/bitrise-add-new-project/bitriseio"
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/manifoldco/promptui"
+)
+
+// projectTypeCandidates returns the supported project types offered first: the bitrise.yml's project type, then the detected platforms.
+func projectTypeCandidates(configProjectType string, detectedPlatforms []string) []string {
+	var candidates []string
+	for _, projectType := range append([]string{configProjectType}, detectedPlatforms...) {
+		if bitriseio.IsSupportedProjectType(projectType) && !sliceutil.IsStringInSlice(projectType, candidates) {
+			candidates = append(candidates, projectType)
+		}
+	}
+	return candidates
+}
+
+func selectProjectType(label string, items []string) (string, error) {
+	prompt := promptui.Select{
+		Label: label,
+		Items: items,
+		Templates: &promptui.SelectTemplates{
+			Label:    fmt.Sprintf("%s {{.}} ", promptui.IconInitial),
+			Selected: "Project type: {{ . | green }}",
+		},
+	}
+
+	_, projectType, err := prompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("scan user input: %s", err)
+	}
+	return projectType, nil
+}
+
+// ProjectType returns the project type of the app, it selects the stack and the default config of the registration.
+// The given project type takes precedence, otherwise the user can confirm or override the bitrise.yml's project type
+// if it is ambiguous (several platforms were detected) or not supported.
+// Without a terminal the first candidate is used, "other" if there is none.
+func ProjectType(configProjectType string, detectedPlatforms []string, projectType string) (string, error) {
+	fmt.Println()
+	log.Infof("PROJECT TYPE")
+
+	if len(detectedPlatforms) > 0 {
+		log.Printf("Detected platforms: %s", colorstring.Green(strings.Join(detectedPlatforms, ", ")))
+	}
+
+	if projectType != "" {
+		if err := bitriseio.ValidateProjectType(projectType); err != nil {
+			return "", err
+		}
+		if configProjectType != "" && configProjectType != projectType {
+			log.Warnf("Overriding the bitrise.yml's project type (%s)", configProjectType)
+		}
+		log.Printf("Project type: %s", colorstring.Green(projectType))
+		return projectType, nil
+	}
+
+	candidates := projectTypeCandidates(configProjectType, detectedPlatforms)
+	if len(candidates) == 1 && candidates[0] == configProjectType {
+		log.Printf("Project type: %s", colorstring.Green(configProjectType))
+		return configProjectType, nil
+	}
+	if len(candidates) == 0 {
+		if configProjectType != "" {
+			log.Warnf("The bitrise.yml's project type (%s) is not supported.", configProjectType)
+		}
+		candidates = []string{bitriseio.OtherProjectType}
+	}
+	if !isInteractive() {
+		log.Printf("Project type: %s (use --project-type to select another one)", colorstring.Green(candidates[0]))
+		return candidates[0], nil
+	}
+
+	const optionOther = "Select another project type"
+	answer, err := selectProjectType("Select the project type", append(candidates, optionOther))
+	if err != nil {
+		return "", err
+	}
+	if answer == optionOther {
+		return selectProjectType("Select the project type", bitriseio.ProjectTypes())
+	}
+	return answer, nil
+}
@@ -0,0 +1,42 @@
+package phases
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_projectTypeCandidates(t *testing.T) {
+	require.Equal(t, []string{"flutter", "android", "ios"}, projectTypeCandidates("flutter", []string{"android", "flutter", "ios"}))
+	require.Equal(t, []string{"android"}, projectTypeCandidates("", []string{"android"}))
+	require.Equal(t, []string{"ios"}, projectTypeCandidates("unknown", []string{"ios"}))
+	require.Empty(t, projectTypeCandidates("unknown", nil))
+}
+
+func TestProjectType(t *testing.T) {
+	interactive := isInteractive
+	projectType, err := ProjectType("flutter", []string{"android", "flutter"}, "android")
+	require.NoError(t, err)
+	require.Equal(t, "android", projectType)
+
+	_, err = ProjectType("flutter", nil, "unknown")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported project type: unknown, supported types: android, ")
+
+	// the only candidate is selected without prompting
+	projectType, err = ProjectType("ios", []string{"ios"}, "")
+	require.NoError(t, err)
+	require.Equal(t, "ios", projectType)
+
+	// without a terminal the first candidate or the generic project type is used
+	isInteractive = func() bool { return false }
+	defer func() { isInteractive = interactive }()
+
+	projectType, err = ProjectType("", []string{"android", "flutter"}, "")
+	require.NoError(t, err)
+	require.Equal(t, "android", projectType)
+
+	projectType, err = ProjectType("", nil, "")
+	require.NoError(t, err)
+	require.Equal(t, "other", projectType)
+}
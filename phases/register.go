@@ -2,11 +2,15 @@ package phases
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"runtime"
+	"strings"
 
 	"github.com/bitrise-io/bitrise-add-new-project/bitriseio"
+	"github.com/bitrise-io/bitrise-add-new-project/sshutil"
 	codesigndocBitriseio "github.com/bitrise-io/codesigndoc/bitriseio"
 	"github.com/bitrise-io/codesigndoc/bitriseio/bitrise"
 	"github.com/bitrise-io/go-utils/colorstring"
@@ -18,22 +22,58 @@ import (
 
 // CreateProjectParams ...
 type CreateProjectParams struct {
-	Repository      bitriseio.RegisterParams
-	SSHKey          bitriseio.RegisterSSHKeyParams
-	RegisterWebhook bool
-	Project         bitriseio.RegisterFinishParams
-	BitriseYML      string
-	WorkflowID      string
-	Branch          string
-	Keystore        bitriseio.UploadKeystoreParams
-	KeystorePth     string
-	CodesignIOS     CodesignResultsIOS
+	Repository       bitriseio.RegisterParams
+	SSHKey           bitriseio.RegisterSSHKeyParams
+	UploadKnownHosts bool
+	RegisterWebhook  bool
+	Project          bitriseio.RegisterFinishParams
+	BitriseYML       string
+	BitriseYMLSource string
+	WorkflowID       string
+	PipelineID       string
+	Branch           string
+	Keystore         bitriseio.UploadKeystoreParams
+	KeystorePth      string
+	CodesignIOS      CodesignResultsIOS
+}
+
+func bitriseYMLToUpload(progress Progress) ([]byte, error) {
+	if progress.BitriseYMLContent == nil {
+		bitriseYML, err := yaml.Marshal(progress.BitriseYML)
+		if err != nil {
+			return nil, fmt.Errorf("bitrise.yml marshal failed: %s", err)
+		}
+		return bitriseYML, nil
+	}
+
+	bitriseYML := progress.BitriseYMLContent
+	// the app's project type, it might be overridden by the user
+	if progress.ProjectType != "" {
+		var err error
+		if bitriseYML, err = setProjectType(bitriseYML, progress.ProjectType); err != nil {
+			return nil, fmt.Errorf("failed to set %s in bitrise.yml: %s", projectTypeKey, err)
+		}
+	}
+	for _, meta := range []struct{ key, value string }{
+		{"stack", progress.Stack},
+		{"machine_type_id", progress.MachineType},
+	} {
+		if meta.value == "" {
+			continue
+		}
+
+		var err error
+		if bitriseYML, err = setBitriseIOMeta(bitriseYML, meta.key, meta.value); err != nil {
+			return nil, fmt.Errorf("failed to set %s in bitrise.yml: %s", meta.key, err)
+		}
+	}
+	return bitriseYML, nil
 }
 
 func toRegistrationParams(progress Progress) (*CreateProjectParams, error) {
-	bitriseYML, err := yaml.Marshal(progress.BitriseYML)
+	bitriseYML, err := bitriseYMLToUpload(progress)
 	if err != nil {
-		return nil, fmt.Errorf("bitrise.yml marshal failed: %s", err)
+		return nil, err
 	}
 	bitriseYMLstr := string(bitriseYML)
 
@@ -53,7 +93,9 @@ func toRegistrationParams(progress Progress) (*CreateProjectParams, error) {
 		AuthSSHPublicKey:                 string(progress.SSHKeys.PublicKey),
 		IsRegisterKeyIntoProviderService: progress.RegisterSSHKey,
 		Username:                         progress.RepoDetails.SSHUsername,
+		HostKeys:                         progress.KnownHosts,
 	}
+	params.UploadKnownHosts = progress.UploadKnownHosts
 
 	params.Project = bitriseio.RegisterFinishParams{
 		ProjectType: progress.ProjectType,
@@ -67,7 +109,9 @@ func toRegistrationParams(progress Progress) (*CreateProjectParams, error) {
 		KeyPassword: progress.Codesign.Android.KeyPassword,
 	}
 	params.BitriseYML = bitriseYMLstr
-	params.WorkflowID = progress.PrimaryWorkflow
+	params.BitriseYMLSource = progress.BitriseYMLSource
+	params.WorkflowID = progress.BuildTarget.WorkflowID
+	params.PipelineID = progress.BuildTarget.PipelineID
 	params.Branch = progress.Branch
 	return &params, nil
 }
@@ -94,51 +138,120 @@ func registerWebhook(app *bitriseio.AppService, inputReader io.Reader) error {
 	return err
 }
 
+func uploadKnownHosts(app *bitriseio.AppService, hostKeys *sshutil.KnownHosts) error {
+	if hostKeys == nil || len(hostKeys.AcceptedHostKeys()) == 0 {
+		return nil
+	}
+
+	return app.Secrets().Upsert(KnownHostsSecretKey, bitriseio.SecretParams{
+		Value: strings.Join(hostKeys.AcceptedHostKeys(), "\n") + "\n",
+	})
+}
+
+// RegisterResult is the registered app and its first build, written to the JSON output.
+type RegisterResult struct {
+	AppSlug string                         `json:"app_slug"`
+	AppURL  string                         `json:"app_url"`
+	Build   bitriseio.TriggerBuildResponse `json:"build"`
+	// BuildTriggerToken starts builds through the incoming webhook or the build trigger API.
+	BuildTriggerToken string `json:"build_trigger_token"`
+	// IncomingWebhookURL is empty if the git provider is unknown.
+	IncomingWebhookURL string `json:"incoming_webhook_url,omitempty"`
+	BuildTriggerCurl   string `json:"build_trigger_curl"`
+}
+
+// WriteRegisterResult writes the result of the registration as JSON,
+// only the owner can read it, as the build trigger token can start builds.
+func WriteRegisterResult(pth string, result RegisterResult) error {
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(pth, append(content, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write file (%s), error: %s", pth, err)
+	}
+	return nil
+}
+
 // Register ...
-func Register(token string, source bitriseio.RegisterSource, progress Progress, inputReader io.Reader) error {
+func Register(token string, source bitriseio.RegisterSource, progress Progress, inputReader io.Reader) (*RegisterResult, error) {
 	fmt.Println()
 	log.Infof("REGISTERING THE PROJECT")
 
 	params, err := toRegistrationParams(progress)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// validated before the app is created, a failing registration would leave a half configured app behind
+	if err := bitriseio.ValidateProjectType(progress.ProjectType); err != nil {
+		return nil, err
+	}
+
+	lint := LintBitriseYML(progress.BitriseYML, progress.ProjectType)
+	if err := lint.Err(); err != nil {
+		return nil, err
 	}
 
 	log.Debugf("Provided params:\n%s", pretty.Object(params))
 
 	client, err := bitriseio.NewClient(token)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	app, err := client.Apps.Register(params.Repository)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !params.Repository.IsPublic && params.SSHKey.AuthSSHPrivateKey != "" {
 		if err := app.RegisterSSHKey(params.SSHKey, params.Repository.RepoURL); err != nil {
-			return err
+			return nil, err
 		}
 	} else {
 		log.Printf("Skipping SSH key registration.")
 	}
 
+	if params.UploadKnownHosts {
+		if err := uploadKnownHosts(app, params.SSHKey.HostKeys); err != nil {
+			// the app is already created, the rest of the registration is still completed
+			log.Errorf("Failed to upload SSH host keys, error: %s", err)
+			log.Warnf("The host keys can be added later as the %s secret on the Secrets tab of the app, with the value:", KnownHostsSecretKey)
+			log.Printf("%s", strings.Join(params.SSHKey.HostKeys.AcceptedHostKeys(), "\n"))
+		}
+	}
+
+	// created before the first build, which would fail without them
+	for _, secret := range progress.Secrets {
+		if err := app.Secrets().Upsert(secret.Name, secret.SecretParams); err != nil {
+			log.Errorf("Failed to create secret %s, error: %s", secret.Name, err)
+		}
+	}
+
 	params.Project.Source = source
 	resp, err := app.RegisterFinish(params.Project)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	log.Debugf(pretty.Object(resp))
 
-	if err := app.UploadBitriseYML(params.BitriseYML); err != nil {
-		return err
+	if params.BitriseYMLSource == BitriseYMLSourceRepository {
+		if err := app.UseRepositoryBitriseYML(); err != nil {
+			return nil, fmt.Errorf("failed to switch to the bitrise.yml stored in the repository, error: %s", err)
+		}
+	} else if err := app.UploadBitriseYML(params.BitriseYML); err != nil {
+		return nil, err
 	}
 
+	webhookURL := incomingWebhookURL(params.Repository.RepoURL, app.Slug, resp.BuildTriggerToken)
 	if params.RegisterWebhook {
 		if resp.IsWebhookAutoRegSupported {
 			if err := registerWebhook(app, inputReader); err != nil {
 				log.Errorf("Failed to register webhook, error: %s", err)
 			}
+		} else if webhookURL != "" {
+			log.Warnf("Webhook registration is not possible right now, add this incoming webhook to the repository manually:")
+			log.Printf("%s", colorstring.Green(webhookURL))
 		} else {
 			log.Errorf("Webhook registration is not possible right now, see options at: https://app.bitrise.io/app/%s#/code", app.Slug)
 		}
@@ -148,7 +261,13 @@ func Register(token string, source bitriseio.RegisterSource, progress Progress,
 
 	if params.KeystorePth != "" {
 		if err := app.UploadKeystore(params.KeystorePth, params.Keystore); err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	for _, file := range progress.GenericFiles {
+		if err := app.UploadGenericProjectFile(file.Path, bitriseio.UploadGenericProjectFileParams{UserEnvKey: file.EnvKey}); err != nil {
+			log.Errorf("Failed to upload %s to the Generic File Storage, error: %s", file.Path, err)
 		}
 	}
 
@@ -156,22 +275,57 @@ func Register(token string, source bitriseio.RegisterSource, progress Progress,
 		// iOS codesigning files upload
 		codesignIOSClient, err := bitrise.NewClient(token)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		codesignIOSClient.SetSelectedAppSlug(app.Slug)
 
 		if _, _, err := codesigndocBitriseio.UploadCodesigningFiles(codesignIOSClient, params.CodesignIOS.certificates, params.CodesignIOS.provisioningProfiles); err != nil {
-			return err
+			return nil, err
 		}
 	} else if runtime.GOOS == "darwin" && isIOSCodesign(params.Project.ProjectType) {
 		log.Printf(`To upload additional iOS code signing files, paste this script into a terminal on macOS and follow the instructions:	
 bash -l -c "$(curl -sfL https://raw.githubusercontent.com/bitrise-io/codesigndoc/master/_scripts/install_wrap.sh)"`)
 	}
 
-	if err := app.TriggerBuild(params.WorkflowID, params.Branch); err != nil {
-		return err
+	buildParams := bitriseio.TriggerBuildParams{
+		WorkflowID: params.WorkflowID,
+		PipelineID: params.PipelineID,
+		Branch:     params.Branch,
+	}
+	build, err := app.TriggerBuild(buildParams)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Printf("Project created: %s", colorstring.Green("https://app.bitrise.io/app/"+app.Slug))
-	return nil
+	curl, err := buildTriggerCurl(app.Slug, resp.BuildTriggerToken, buildParams)
+	if err != nil {
+		return nil, err
+	}
+
+	appURL := "https://app.bitrise.io/app/" + app.Slug
+	log.Printf("Project created: %s", colorstring.Green(appURL))
+	if build.BuildURL != "" {
+		log.Printf("First build (#%d) started: %s", build.BuildNumber, colorstring.Green(build.BuildURL))
+	}
+	if len(lint.Warnings) > 0 {
+		log.Warnf("Review the bitrise.yml warnings in the Workflow Editor:")
+		logLintResult(lint)
+	}
+
+	fmt.Println()
+	log.Infof("MANUAL BUILD TRIGGERS")
+	if webhookURL != "" {
+		log.Printf("Incoming webhook URL (e.g. for self-hosted git servers): %s", webhookURL)
+	}
+	log.Printf("Start builds from other CI systems or scripts with:")
+	log.Printf("%s", curl)
+
+	return &RegisterResult{
+		AppSlug:            app.Slug,
+		AppURL:             appURL,
+		Build:              *build,
+		BuildTriggerToken:  resp.BuildTriggerToken,
+		IncomingWebhookURL: webhookURL,
+		BuildTriggerCurl:   curl,
+	}, nil
 }
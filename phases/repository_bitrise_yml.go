@@ -0,0 +1,135 @@
+package phases
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/manifoldco/promptui"
+)
+
+// Where the app reads the bitrise.yml from.
+const (
+	BitriseYMLSourceWebsite    = "website"
+	BitriseYMLSourceRepository = "repository"
+)
+
+// fetchRepositoryBitriseYML returns the bitrise.yml committed on the branch of the origin remote.
+// The user's git is used, so the same credentials work as for pushing the file.
+func fetchRepositoryBitriseYML(searchDir, branch string) ([]byte, error) {
+	fetch := command.New("git", "fetch", "origin", branch).SetDir(searchDir)
+	if out, err := fetch.RunAndReturnTrimmedCombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to fetch branch (%s) from origin, error: %s: %s", branch, err, out)
+	}
+
+	content, err := gitShow(searchDir, "FETCH_HEAD", bitriseYMLName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from branch (%s) of origin, error: %s", bitriseYMLName, branch, err)
+	}
+	return content, nil
+}
+
+// validateRepositoryBitriseYML parses and lints the bitrise.yml stored in the repository,
+// the config modules it includes are read from the fetched revision.
+func validateRepositoryBitriseYML(searchDir string, content []byte) (models.BitriseDataModel, error) {
+	if isModularBitriseYML(content) {
+		merged, err := mergeRepositoryBitriseYML(searchDir, "FETCH_HEAD")
+		if err != nil {
+			return models.BitriseDataModel{}, err
+		}
+		log.Printf("The modular layout is kept, bitrise.io merges the modules from the repository on each build.")
+		content = merged
+	}
+
+	bitriseYML, warnings, err := ParseBitriseYMLFile(bytes.NewReader(content))
+	if err != nil {
+		return models.BitriseDataModel{}, err
+	}
+	for _, warning := range warnings {
+		log.Warnf(warning)
+	}
+
+	lint := LintBitriseYML(bitriseYML, bitriseYML.ProjectType)
+	if err := lint.Err(); err != nil {
+		return models.BitriseDataModel{}, err
+	}
+	if len(lint.Warnings) > 0 {
+		log.Warnf("The bitrise.yml has warnings:")
+		logLintResult(lint)
+	}
+	if !hasTriggers(bitriseYML) {
+		log.Warnf("The bitrise.yml has no triggers, git events will not start builds until triggers are added to it in the repository.")
+	}
+	return bitriseYML, nil
+}
+
+func getRepositoryBitriseYML(searchDir, branch string) (models.BitriseDataModel, []byte, error) {
+	const (
+		optionRetry = "Retry"
+		optionAbort = "Abort"
+	)
+
+	for {
+		content, err := fetchRepositoryBitriseYML(searchDir, branch)
+		if err == nil {
+			var bitriseYML models.BitriseDataModel
+			if bitriseYML, err = validateRepositoryBitriseYML(searchDir, content); err == nil {
+				return bitriseYML, content, nil
+			}
+		}
+
+		log.Errorf("%s", err)
+		log.Warnf("Commit and push a valid %s to the %s branch, then retry.", bitriseYMLName, branch)
+
+		prompt := promptui.Select{
+			Label: "How do you want to proceed?",
+			Items: []string{optionRetry, optionAbort},
+			Templates: &promptui.SelectTemplates{
+				Label:    fmt.Sprintf("%s {{.}} ", promptui.IconInitial),
+				Selected: "{{ . }}",
+			},
+		}
+
+		_, answer, err := prompt.Run()
+		if err != nil {
+			return models.BitriseDataModel{}, nil, fmt.Errorf("scan user input: %s", err)
+		}
+		if answer == optionAbort {
+			return models.BitriseDataModel{}, nil, fmt.Errorf("no valid %s on the %s branch of the repository", bitriseYMLName, branch)
+		}
+	}
+}
+
+// RepositoryBitriseYML returns the bitrise.yml stored on the default branch of the repository, its content,
+// the pipeline or workflow for the first build and the default branch.
+func RepositoryBitriseYML(searchDir string) (models.BitriseDataModel, []byte, BuildTarget, string, error) {
+	fmt.Println()
+	log.Infof("SETUP BITRISE.YML")
+	log.Printf("The app will use the %s stored in the repository.", bitriseYMLName)
+
+	branch, err := currentBranch(searchDir)
+	if err != nil {
+		return models.BitriseDataModel{}, nil, BuildTarget{}, "", fmt.Errorf("failed to get current branch, error: %s", err)
+	}
+
+	branchName, err := askBranch(branch.tracking)
+	if err != nil {
+		return models.BitriseDataModel{}, nil, BuildTarget{}, "", fmt.Errorf("failed to ask for primary branch, error: %s", err)
+	}
+
+	bitriseYML, content, err := getRepositoryBitriseYML(searchDir, branchName)
+	if err != nil {
+		return models.BitriseDataModel{}, nil, BuildTarget{}, "", err
+	}
+	log.Donef("Found valid %s on branch: %s", bitriseYMLName, colorstring.Green(branchName))
+
+	target, err := selectBuildTarget(bitriseYML, os.Stdin)
+	if err != nil {
+		return models.BitriseDataModel{}, nil, BuildTarget{}, "", fmt.Errorf("failed to select the first build's target, error: %s", err)
+	}
+	return bitriseYML, content, target, branchName, nil
+}
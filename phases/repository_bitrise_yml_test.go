@@ -0,0 +1,41 @@
+package phases
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_fetchRepositoryBitriseYML(t *testing.T) {
+	remoteDir := t.TempDir()
+	_, err := git.PlainInit(remoteDir, true)
+	require.NoError(t, err)
+
+	dir, repo := initTestRepo(t)
+	_, err = repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remoteDir}})
+	require.NoError(t, err)
+
+	content := "format_version: \"13\"\nworkflows:\n  primary: {}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, bitriseYMLName), []byte(content), 0644))
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = worktree.Add(bitriseYMLName)
+	require.NoError(t, err)
+	_, err = worktree.Commit("Add bitrise.yml", &git.CommitOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Push(&git.PushOptions{RemoteName: "origin", RefSpecs: []config.RefSpec{"refs/heads/*:refs/heads/*"}}))
+
+	// local changes are ignored, the pushed version is returned
+	require.NoError(t, os.WriteFile(filepath.Join(dir, bitriseYMLName), []byte("changed"), 0644))
+
+	got, err := fetchRepositoryBitriseYML(dir, "master")
+	require.NoError(t, err)
+	require.Equal(t, content, string(got))
+
+	_, err = fetchRepositoryBitriseYML(dir, "missing")
+	require.Error(t, err)
+}
@@ -0,0 +1,302 @@
+package phases
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/manifoldco/promptui"
+)
+
+const defaultBitriseYMLBranch = "add-bitrise-yml"
+
+func askYesNo(label, selected string) (bool, error) {
+	const (
+		answerYes = "Yes"
+		answerNo  = "No"
+	)
+
+	prompt := promptui.Select{
+		Label: label,
+		Items: []string{answerYes, answerNo},
+		Templates: &promptui.SelectTemplates{
+			Label:    fmt.Sprintf("%s {{.}} ", promptui.IconInitial),
+			Selected: selected + ": {{ . | green }}",
+		},
+	}
+
+	_, answer, err := prompt.Run()
+	if err != nil {
+		return false, fmt.Errorf("scan user input: %s", err)
+	}
+	return answer == answerYes, nil
+}
+
+func askCommitBranch() (string, error) {
+	prompt := promptui.Prompt{
+		Label:   "Name of the new branch",
+		Default: defaultBitriseYMLBranch,
+		Validate: func(name string) error {
+			if !plumbing.NewBranchReferenceName(strings.TrimSpace(name)).IsBranch() || strings.TrimSpace(name) == "" {
+				return errors.New("invalid branch name")
+			}
+			return nil
+		},
+	}
+
+	branch, err := prompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("prompt user: %s", err)
+	}
+	return strings.TrimSpace(branch), nil
+}
+
+// updateTree returns the hash of the tree with the blob stored at the given path, the missing subtrees are created.
+func updateTree(s storer.EncodedObjectStorer, tree *object.Tree, parts []string, blob plumbing.Hash) (plumbing.Hash, error) {
+	var entries []object.TreeEntry
+	if tree != nil {
+		entries = append(entries, tree.Entries...)
+	}
+
+	entry := object.TreeEntry{Name: parts[0], Mode: filemode.Regular, Hash: blob}
+	if len(parts) > 1 {
+		var subtree *object.Tree
+		for _, e := range entries {
+			if e.Name == parts[0] && e.Mode == filemode.Dir {
+				var err error
+				if subtree, err = object.GetTree(s, e.Hash); err != nil {
+					return plumbing.ZeroHash, err
+				}
+			}
+		}
+		hash, err := updateTree(s, subtree, parts[1:], blob)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entry = object.TreeEntry{Name: parts[0], Mode: filemode.Dir, Hash: hash}
+	}
+
+	replaced := false
+	for i, e := range entries {
+		if e.Name == entry.Name {
+			if e.Mode == filemode.Executable && entry.Mode == filemode.Regular {
+				entry.Mode = e.Mode
+			}
+			entries[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+
+	// git orders the entries by name, with a trailing slash for directories
+	sortKey := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(entries, func(i, j int) bool { return sortKey(entries[i]) < sortKey(entries[j]) })
+
+	obj := s.NewEncodedObject()
+	if err := (&object.Tree{Entries: entries}).Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// commitBitriseYML commits the bitrise.yml of the search dir on a new branch created from HEAD.
+// The checkout is not changed: HEAD, the index and the local changes are kept, only the new branch is written.
+// It returns the hash of the commit and the name of the branch checked out.
+func commitBitriseYML(searchDir, branch string) (string, string, error) {
+	repo, err := git.PlainOpenWithOptions(searchDir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open git repository (%s), error: %s", searchDir, err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", "", err
+	}
+
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	if _, err := repo.Reference(branchRef, false); err == nil {
+		return "", "", fmt.Errorf("branch (%s) already exists", branch)
+	} else if err != plumbing.ErrReferenceNotFound {
+		return "", "", err
+	}
+
+	// the search dir might be a subdirectory of the repository
+	root, err := filepath.EvalSymlinks(worktree.Filesystem.Root())
+	if err != nil {
+		return "", "", err
+	}
+	dir, err := filepath.EvalSymlinks(searchDir)
+	if err != nil {
+		return "", "", err
+	}
+	relPth, err := filepath.Rel(root, filepath.Join(dir, bitriseYMLName))
+	if err != nil {
+		return "", "", err
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, bitriseYMLName))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s, error: %s", bitriseYMLName, err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get HEAD, error: %s", err)
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", "", err
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return "", "", err
+	}
+
+	blob := repo.Storer.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	w, err := blob.Writer()
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := w.Write(content); err != nil {
+		return "", "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", "", err
+	}
+	blobHash, err := repo.Storer.SetEncodedObject(blob)
+	if err != nil {
+		return "", "", err
+	}
+
+	treeHash, err := updateTree(repo.Storer, headTree, strings.Split(filepath.ToSlash(relPth), "/"), blobHash)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create tree, error: %s", err)
+	}
+
+	// the author is read from the git config, like by git commit
+	opts := &git.CommitOptions{Parents: []plumbing.Hash{head.Hash()}}
+	if err := opts.Validate(repo); err != nil {
+		return "", "", err
+	}
+	if opts.Author == nil {
+		return "", "", fmt.Errorf("author not found, set user.name and user.email in the git config")
+	}
+
+	commit := &object.Commit{
+		Author:       *opts.Author,
+		Committer:    *opts.Committer,
+		Message:      "Add bitrise.yml",
+		TreeHash:     treeHash,
+		ParentHashes: opts.Parents,
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return "", "", err
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return "", "", err
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(branchRef, hash)); err != nil {
+		return "", "", fmt.Errorf("failed to create branch (%s), error: %s", branch, err)
+	}
+
+	current := "a detached HEAD"
+	if head.Name().IsBranch() {
+		current = head.Name().Short()
+	}
+	return hash.String(), current, nil
+}
+
+// SaveBitriseYML offers to write the generated bitrise.yml into the repository root
+// and to commit it on a new branch, so it can be reviewed in a pull request.
+func SaveBitriseYML(searchDir string, content []byte) error {
+	fmt.Println()
+	log.Infof("SAVE BITRISE.YML")
+
+	save, err := askYesNo("Do you want to save the generated bitrise.yml into the repository?", "Save bitrise.yml")
+	if err != nil {
+		return err
+	}
+	if !save {
+		log.Printf("Skipping saving bitrise.yml, the configuration is only stored on bitrise.io.")
+		return nil
+	}
+
+	pth := filepath.Join(searchDir, bitriseYMLName)
+	existing, err := os.ReadFile(pth)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read file (%s), error: %s", pth, err)
+	}
+
+	switch {
+	case err == nil && bytes.Equal(existing, content):
+		log.Printf("%s is up to date.", pth)
+		return nil
+	case err == nil:
+		overwrite, err := askYesNo(fmt.Sprintf("%s already exists, do you want to overwrite it?", bitriseYMLName), "Overwrite bitrise.yml")
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			log.Printf("Keeping the existing %s.", pth)
+			return nil
+		}
+	}
+
+	if err := os.WriteFile(pth, content, 0644); err != nil {
+		return fmt.Errorf("failed to write file (%s), error: %s", pth, err)
+	}
+	log.Donef("Saved: %s", pth)
+
+	commit, err := askYesNo("Do you want to commit the bitrise.yml on a new branch?", "Commit bitrise.yml")
+	if err != nil {
+		return err
+	}
+	if !commit {
+		return nil
+	}
+
+	for {
+		branch, err := askCommitBranch()
+		if err != nil {
+			return err
+		}
+
+		hash, current, err := commitBitriseYML(searchDir, branch)
+		if err != nil {
+			log.Errorf("Failed to commit bitrise.yml, error: %s", err)
+			retry, err := askYesNo("Do you want to try again?", "Retry")
+			if err != nil {
+				return err
+			}
+			if retry {
+				continue
+			}
+			return nil
+		}
+
+		log.Donef("Committed bitrise.yml on branch %s (%s)", branch, hash[:7])
+		log.Printf("You are still on %s, the saved bitrise.yml is left in the working directory.", current)
+		log.Printf("Push the branch and open a pull request to review it: %s", colorstring.Green("git push -u origin "+branch))
+		return nil
+	}
+}
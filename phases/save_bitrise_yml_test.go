@@ -0,0 +1,124 @@
+package phases
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func initTestRepo(t *testing.T) (string, *git.Repository) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	cfg, err := repo.Config()
+	require.NoError(t, err)
+	cfg.User.Name = "Test"
+	cfg.User.Email = "test@example.com"
+	require.NoError(t, repo.SetConfig(cfg))
+
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme\n"), 0644))
+	_, err = worktree.Add("README.md")
+	require.NoError(t, err)
+	_, err = worktree.Commit("Initial commit", &git.CommitOptions{})
+	require.NoError(t, err)
+
+	return dir, repo
+}
+
+func Test_commitBitriseYML(t *testing.T) {
+	dir, repo := initTestRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("changed\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, bitriseYMLName), []byte("format_version: \"13\"\n"), 0644))
+
+	headBefore, err := repo.Head()
+	require.NoError(t, err)
+
+	hash, current, err := commitBitriseYML(dir, "add-bitrise-yml")
+	require.NoError(t, err)
+	require.Equal(t, "master", current)
+
+	// the checkout is not changed
+	head, err := repo.Head()
+	require.NoError(t, err)
+	require.Equal(t, headBefore.Name(), head.Name())
+	require.Equal(t, headBefore.Hash(), head.Hash())
+
+	branch, err := repo.Reference(plumbing.NewBranchReferenceName("add-bitrise-yml"), false)
+	require.NoError(t, err)
+	require.Equal(t, hash, branch.Hash().String())
+
+	commit, err := repo.CommitObject(branch.Hash())
+	require.NoError(t, err)
+	require.Equal(t, []plumbing.Hash{head.Hash()}, commit.ParentHashes)
+	require.Equal(t, "Test", commit.Author.Name)
+	stats, err := commit.Stats()
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, bitriseYMLName, stats[0].Name)
+
+	// unrelated local changes are kept, but not committed
+	readme, err := os.ReadFile(filepath.Join(dir, "README.md"))
+	require.NoError(t, err)
+	require.Equal(t, "changed\n", string(readme))
+}
+
+func Test_commitBitriseYML_subdirectory(t *testing.T) {
+	dir, repo := initTestRepo(t)
+	searchDir := filepath.Join(dir, "app")
+	require.NoError(t, os.MkdirAll(searchDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(searchDir, bitriseYMLName), []byte("format_version: \"13\"\n"), 0644))
+
+	hash, _, err := commitBitriseYML(searchDir, "add-bitrise-yml")
+	require.NoError(t, err)
+
+	commit, err := repo.CommitObject(plumbing.NewHash(hash))
+	require.NoError(t, err)
+	file, err := commit.File("app/" + bitriseYMLName)
+	require.NoError(t, err)
+	content, err := file.Contents()
+	require.NoError(t, err)
+	require.Equal(t, "format_version: \"13\"\n", content)
+	_, err = commit.File("README.md")
+	require.NoError(t, err)
+}
+
+func Test_commitBitriseYML_stagedChanges(t *testing.T) {
+	dir, repo := initTestRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("changed\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, bitriseYMLName), []byte("format_version: \"13\"\n"), 0644))
+
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = worktree.Add("README.md")
+	require.NoError(t, err)
+
+	hash, _, err := commitBitriseYML(dir, "add-bitrise-yml")
+	require.NoError(t, err)
+
+	// the staged changes are neither committed nor unstaged
+	commit, err := repo.CommitObject(plumbing.NewHash(hash))
+	require.NoError(t, err)
+	stats, err := commit.Stats()
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, bitriseYMLName, stats[0].Name)
+
+	status, err := worktree.Status()
+	require.NoError(t, err)
+	require.Equal(t, git.Modified, status.File("README.md").Staging)
+}
+
+func Test_commitBitriseYML_existingBranch(t *testing.T) {
+	dir, _ := initTestRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, bitriseYMLName), []byte("format_version: \"13\"\n"), 0644))
+
+	_, _, err := commitBitriseYML(dir, "master")
+	require.EqualError(t, err, "branch (master) already exists")
+}
@@ -0,0 +1,185 @@
+package phases
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/bitrise-init/models"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/sliceutil"
+)
+
+// scannerPathEnvKeys are the env keys of the scanner options whose values are paths relative to the scanned directory.
+var scannerPathEnvKeys = map[string]bool{
+	"BITRISE_PROJECT_PATH":             true,
+	"PROJECT_LOCATION":                 true,
+	"BITRISE_FLUTTER_PROJECT_LOCATION": true,
+	"PROJECT_ROOT_DIR":                 true,
+	"WORKDIR":                          true,
+	"NODEJS_PROJECT_DIR":               true,
+	"IONIC_WORK_DIR":                   true,
+	"CORDOVA_WORK_DIR":                 true,
+	"FASTLANE_WORK_DIR":                true,
+}
+
+// scanDirectory returns the absolute path of the directory to scan and its path relative to the repository root.
+func scanDirectory(searchDir, scanDir string) (string, string, error) {
+	if scanDir == "" {
+		return searchDir, ".", nil
+	}
+
+	absScanDir := scanDir
+	if !filepath.IsAbs(absScanDir) {
+		absScanDir = filepath.Join(searchDir, scanDir)
+	}
+	absScanDir = filepath.Clean(absScanDir)
+
+	relDir, err := filepath.Rel(searchDir, absScanDir)
+	if err != nil || relDir == ".." || strings.HasPrefix(relDir, ".."+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("scan directory (%s) is not inside the repository (%s)", scanDir, searchDir)
+	}
+
+	info, err := os.Stat(absScanDir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to check scan directory (%s), error: %s", scanDir, err)
+	}
+	if !info.IsDir() {
+		return "", "", fmt.Errorf("scan directory (%s) is not a directory", scanDir)
+	}
+
+	return absScanDir, filepath.ToSlash(relDir), nil
+}
+
+// isExcluded reports whether the path (relative to the scanned directory) or its name matches any of the glob patterns.
+func isExcluded(relPth string, patterns []string) bool {
+	relPth = filepath.ToSlash(relPth)
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+		if match, _ := path.Match(pattern, relPth); match {
+			return true
+		}
+		if match, _ := path.Match(pattern, path.Base(relPth)); match {
+			return true
+		}
+	}
+	return false
+}
+
+// mirrorSkippedDirs are never scanned.
+var mirrorSkippedDirs = []string{".git"}
+
+// linkFile links the file into the mirror, hard links are used instead of symlinks,
+// so resolving the path of a mirrored file does not lead back to the original tree with the excluded paths.
+// The file is copied if it can not be linked (e.g. the temporary directory is on another file system).
+func linkFile(pth, target string) error {
+	if err := os.Link(pth, target); err == nil {
+		return nil
+	}
+
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(pth)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target, content, info.Mode().Perm())
+}
+
+// mirrorScanDir creates a temporary copy of the directory tree without the .git directory and the excluded paths,
+// files are hard linked to the originals where possible, so the scanner reads them without copying their content.
+// Symlinks are copied as they are, relative symlinks resolve inside the mirror.
+func mirrorScanDir(scanDir string, exclude []string) (string, error) {
+	mirrorDir, err := os.MkdirTemp("", "scan-dir")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory, error: %s", err)
+	}
+
+	excluded := 0
+	if err := filepath.WalkDir(scanDir, func(pth string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPth, err := filepath.Rel(scanDir, pth)
+		if err != nil {
+			return err
+		}
+		if relPth == "." {
+			return nil
+		}
+
+		if d.IsDir() && sliceutil.IsStringInSlice(d.Name(), mirrorSkippedDirs) {
+			return filepath.SkipDir
+		}
+		if isExcluded(relPth, exclude) {
+			log.Debugf("Excluding %s from the scan", relPth)
+			excluded++
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := filepath.Join(mirrorDir, relPth)
+		switch {
+		case d.IsDir():
+			return os.Mkdir(target, 0700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(pth)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return linkFile(pth, target)
+		}
+		return nil
+	}); err != nil {
+		if err := os.RemoveAll(mirrorDir); err != nil {
+			log.Warnf("Failed to remove temporary directory (%s), error: %s", mirrorDir, err)
+		}
+		return "", fmt.Errorf("failed to prepare scan directory, error: %s", err)
+	}
+
+	log.Printf("Excluded %d path(s) from the scan.", excluded)
+	return mirrorDir, nil
+}
+
+// relocateOption rewrites the path values of the option tree to be relative to the repository root instead of the scanned directory.
+func relocateOption(option *models.OptionNode, relDir string) {
+	if option == nil {
+		return
+	}
+
+	if scannerPathEnvKeys[option.EnvKey] {
+		children := map[string]*models.OptionNode{}
+		for value, child := range option.ChildOptionMap {
+			if value != models.UserInputOptionDefaultValue && !path.IsAbs(value) && !strings.HasPrefix(value, "$") {
+				value = path.Join(relDir, filepath.ToSlash(value))
+			}
+			children[value] = child
+		}
+		option.ChildOptionMap = children
+	}
+
+	for _, child := range option.ChildOptionMap {
+		relocateOption(child, relDir)
+	}
+}
+
+// relocateScanResult makes the project paths of the scan result relative to the repository root,
+// builds run in the root of the cloned repository.
+func relocateScanResult(scanResult *models.ScanResultModel, relDir string) {
+	if relDir == "." {
+		return
+	}
+	for platform, option := range scanResult.ScannerToOptionRoot {
+		relocateOption(&option, relDir)
+		scanResult.ScannerToOptionRoot[platform] = option
+	}
+}
@@ -0,0 +1,122 @@
+package phases
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/bitrise-init/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_scanDirectory(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "apps", "mobile"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "README.md"), nil, 0600))
+
+	dir, relDir, err := scanDirectory(root, "")
+	require.NoError(t, err)
+	require.Equal(t, root, dir)
+	require.Equal(t, ".", relDir)
+
+	dir, relDir, err = scanDirectory(root, "apps/mobile/")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(root, "apps", "mobile"), dir)
+	require.Equal(t, "apps/mobile", relDir)
+
+	_, _, err = scanDirectory(root, "../other")
+	require.EqualError(t, err, "scan directory (../other) is not inside the repository ("+root+")")
+
+	_, _, err = scanDirectory(root, "README.md")
+	require.EqualError(t, err, "scan directory (README.md) is not a directory")
+}
+
+func Test_isExcluded(t *testing.T) {
+	patterns := []string{"node_modules", "sdk/*", "*.zip", "samples/"}
+
+	require.True(t, isExcluded("node_modules", patterns))
+	require.True(t, isExcluded("web/node_modules", patterns))
+	require.True(t, isExcluded("sdk/vendor", patterns))
+	require.True(t, isExcluded("assets/archive.zip", patterns))
+	require.True(t, isExcluded("samples", patterns))
+	require.False(t, isExcluded("app/src", patterns))
+	require.False(t, isExcluded("sdk", patterns))
+}
+
+func Test_mirrorScanDir(t *testing.T) {
+	scanDir := t.TempDir()
+	for _, pth := range []string{"android/build.gradle", "node_modules/lib/build.gradle", "samples/App.xcodeproj/project.pbxproj"} {
+		pth = filepath.Join(scanDir, pth)
+		require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0700))
+		require.NoError(t, os.WriteFile(pth, []byte("content"), 0600))
+	}
+
+	mirrorDir, err := mirrorScanDir(scanDir, []string{"node_modules", "samples"})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(mirrorDir))
+	}()
+
+	content, err := os.ReadFile(filepath.Join(mirrorDir, "android", "build.gradle"))
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+	require.NoDirExists(t, filepath.Join(mirrorDir, "node_modules"))
+	require.NoDirExists(t, filepath.Join(mirrorDir, "samples"))
+
+	// the originals are kept
+	require.FileExists(t, filepath.Join(scanDir, "node_modules", "lib", "build.gradle"))
+}
+
+func Test_mirrorScanDir_resolvedPaths(t *testing.T) {
+	scanDir := t.TempDir()
+	for _, pth := range []string{".git/config", "android/build.gradle", "android/node_modules/lib/build.gradle"} {
+		pth = filepath.Join(scanDir, pth)
+		require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0700))
+		require.NoError(t, os.WriteFile(pth, []byte("content"), 0600))
+	}
+	require.NoError(t, os.Symlink("build.gradle", filepath.Join(scanDir, "android", "settings.gradle")))
+
+	mirrorDir, err := mirrorScanDir(scanDir, []string{"node_modules"})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(mirrorDir))
+	}()
+
+	require.NoDirExists(t, filepath.Join(mirrorDir, ".git"))
+
+	// scanners resolving the symlinks of the files stay in the mirror, where the excluded directory does not exist
+	for _, name := range []string{"build.gradle", "settings.gradle"} {
+		resolved, err := filepath.EvalSymlinks(filepath.Join(mirrorDir, "android", name))
+		require.NoError(t, err)
+		require.NoDirExists(t, filepath.Join(filepath.Dir(resolved), "node_modules"))
+
+		content, err := os.ReadFile(resolved)
+		require.NoError(t, err)
+		require.Equal(t, "content", string(content))
+	}
+}
+
+func Test_relocateScanResult(t *testing.T) {
+	root := models.NewOption("Project or Workspace path", "", "BITRISE_PROJECT_PATH", models.TypeSelector)
+	scheme := models.NewOption("Scheme name", "", "BITRISE_SCHEME", models.TypeSelector)
+	root.AddOption("ios/App.xcodeproj", scheme)
+	scheme.AddConfig("App", models.NewConfigOption("ios-config", nil))
+
+	androidRoot := models.NewOption("The root directory of an Android project", "", "PROJECT_LOCATION", models.TypeSelector)
+	module := models.NewOption("Module", "", "MODULE", models.TypeUserInput)
+	androidRoot.AddOption(".", module)
+	module.AddConfig(models.UserInputOptionDefaultValue, models.NewConfigOption("android-config", nil))
+
+	scanResult := models.ScanResultModel{
+		ScannerToOptionRoot: map[string]models.OptionNode{"ios": *root, "android": *androidRoot},
+	}
+	relocateScanResult(&scanResult, "apps/mobile")
+
+	iosRoot := scanResult.ScannerToOptionRoot["ios"]
+	require.Equal(t, []string{"apps/mobile/ios/App.xcodeproj"}, sortedOptionValues(iosRoot))
+	require.Equal(t, []string{"App"}, sortedOptionValues(*iosRoot.ChildOptionMap["apps/mobile/ios/App.xcodeproj"]))
+
+	androidRootOption := scanResult.ScannerToOptionRoot["android"]
+	require.Equal(t, []string{"apps/mobile"}, sortedOptionValues(androidRootOption))
+	require.Equal(t, []string{models.UserInputOptionDefaultValue}, sortedOptionValues(*androidRootOption.ChildOptionMap["apps/mobile"]))
+}
@@ -0,0 +1,89 @@
+package phases
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	scannerModels "github.com/bitrise-io/bitrise-init/models"
+	"github.com/bitrise-io/bitrise-init/output"
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/bitrise-io/go-utils/log"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	scanResultName   = "result"
+	scanIconsDirName = "icons"
+)
+
+// writeScanResult saves the scan result and the detected icons to the output directory,
+// in the same layout as the bitrise-init CLI.
+func writeScanResult(scanResult scannerModels.ScanResultModel, outputDir string, format output.Format) (string, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create scan output directory (%s), error: %s", outputDir, err)
+	}
+
+	if len(scanResult.Icons) > 0 {
+		iconsDir := filepath.Join(outputDir, scanIconsDirName)
+		if err := os.MkdirAll(iconsDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create icons directory, error: %s", err)
+		}
+		for _, icon := range scanResult.Icons {
+			content, err := os.ReadFile(icon.Path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read icon (%s), error: %s", icon.Path, err)
+			}
+			if err := os.WriteFile(filepath.Join(iconsDir, icon.Filename), content, 0644); err != nil {
+				return "", fmt.Errorf("failed to write icon (%s), error: %s", icon.Filename, err)
+			}
+		}
+	}
+
+	pth, err := output.WriteToFile(scanResult, format, filepath.Join(outputDir, scanResultName))
+	if err != nil {
+		return "", fmt.Errorf("failed to write scan result, error: %s", err)
+	}
+	return pth, nil
+}
+
+// writeScanConfig saves the config selected from the scan result next to the scan result.
+func writeScanConfig(bitriseYML models.BitriseDataModel, outputDir string) (string, error) {
+	content, err := yaml.Marshal(bitriseYML)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal selected config, error: %s", err)
+	}
+
+	pth := filepath.Join(outputDir, bitriseYMLName)
+	if err := os.WriteFile(pth, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write selected config, error: %s", err)
+	}
+	return pth, nil
+}
+
+// saveScanResult writes the scan result if an output directory is configured,
+// failing to save the debug output does not stop the wizard.
+func saveScanResult(scanResult scannerModels.ScanResultModel, options ScannerOptions) {
+	if options.OutputDir == "" {
+		return
+	}
+	pth, err := writeScanResult(scanResult, options.OutputDir, options.OutputFormat)
+	if err != nil {
+		log.Warnf("Failed to save scan result, error: %s", err)
+		return
+	}
+	log.Printf("Scan result saved: %s", pth)
+}
+
+// saveScanConfig writes the selected config if an output directory is configured.
+func saveScanConfig(bitriseYML models.BitriseDataModel, options ScannerOptions) {
+	if options.OutputDir == "" {
+		return
+	}
+	pth, err := writeScanConfig(bitriseYML, options.OutputDir)
+	if err != nil {
+		log.Warnf("Failed to save selected config, error: %s", err)
+		return
+	}
+	log.Printf("Selected config saved: %s", pth)
+}
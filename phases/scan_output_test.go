@@ -0,0 +1,45 @@
+package phases
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	scannerModels "github.com/bitrise-io/bitrise-init/models"
+	"github.com/bitrise-io/bitrise-init/output"
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_writeScanResult(t *testing.T) {
+	iconPth := filepath.Join(t.TempDir(), "icon.png")
+	require.NoError(t, os.WriteFile(iconPth, []byte("png"), 0600))
+
+	scanResult := testScanResult()
+	scanResult.ScannerToWarnings = map[string]scannerModels.Warnings{"ios": {"no shared schemes"}}
+	scanResult.Icons = scannerModels.Icons{{Filename: "0a1b.png", Path: iconPth}}
+
+	outputDir := filepath.Join(t.TempDir(), "scan")
+	pth, err := writeScanResult(scanResult, outputDir, output.JSONFormat)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(outputDir, "result.json"), pth)
+
+	content, err := os.ReadFile(pth)
+	require.NoError(t, err)
+	var written scannerModels.ScanResultModel
+	require.NoError(t, json.Unmarshal(content, &written))
+	require.Equal(t, scanResult.ScannerToBitriseConfigMap, written.ScannerToBitriseConfigMap)
+	require.Equal(t, scanResult.ScannerToWarnings, written.ScannerToWarnings)
+	require.Equal(t, []string{"App.xcodeproj", "Other.xcworkspace"}, sortedOptionValues(written.ScannerToOptionRoot["ios"]))
+
+	icon, err := os.ReadFile(filepath.Join(outputDir, "icons", "0a1b.png"))
+	require.NoError(t, err)
+	require.Equal(t, "png", string(icon))
+
+	pth, err = writeScanConfig(models.BitriseDataModel{FormatVersion: "11", ProjectType: "ios"}, outputDir)
+	require.NoError(t, err)
+	content, err = os.ReadFile(pth)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "project_type: ios")
+}
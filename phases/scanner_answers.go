@@ -0,0 +1,204 @@
+package phases
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/bitrise-init/models"
+	bitriseModels "github.com/bitrise-io/bitrise/v2/models"
+	envmanModels "github.com/bitrise-io/envman/v2/models"
+	"gopkg.in/yaml.v2"
+)
+
+// ScannerPlatformAnswerKey selects the platform if the scanner detected several ones.
+const ScannerPlatformAnswerKey = "platform"
+
+// ScannerAnswers are the values of the scanner options, keyed by the option's env key (e.g. BITRISE_SCHEME) or title.
+type ScannerAnswers map[string]string
+
+// ParseScannerAnswers reads the answers from a YAML (or JSON) file of key-value pairs
+// and from KEY=VALUE pairs, the latter take precedence.
+func ParseScannerAnswers(pairs []string, pth string) (ScannerAnswers, error) {
+	answers := ScannerAnswers{}
+
+	if pth != "" {
+		content, err := os.ReadFile(pth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read scanner answers file (%s), error: %s", pth, err)
+		}
+		var fileAnswers map[string]interface{}
+		if err := yaml.Unmarshal(content, &fileAnswers); err != nil {
+			return nil, fmt.Errorf("failed to parse scanner answers file (%s), error: %s", pth, err)
+		}
+		for key, value := range fileAnswers {
+			answers[key] = fmt.Sprint(value)
+		}
+	}
+
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid scanner answer (%s), expected format: KEY=VALUE", pair)
+		}
+		answers[key] = value
+	}
+
+	return answers, nil
+}
+
+func sortedOptionValues(option models.OptionNode) []string {
+	var values []string
+	for value := range option.ChildOptionMap {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+func optionName(option models.OptionNode) string {
+	if option.EnvKey != "" {
+		return fmt.Sprintf("%s (%s)", option.Title, option.EnvKey)
+	}
+	return option.Title
+}
+
+// answer returns the answer for the option by its env key or title, and marks it as used.
+func (a ScannerAnswers) answer(option models.OptionNode, used map[string]bool) (string, bool) {
+	for _, key := range []string{option.EnvKey, option.Title} {
+		if key == "" {
+			continue
+		}
+		if value, ok := a[key]; ok {
+			used[key] = true
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// optionValue returns the value of the option from the answers, or the only possible value.
+func optionValue(option models.OptionNode, answers ScannerAnswers, used map[string]bool) (string, error) {
+	value, answered := answers.answer(option, used)
+	values := sortedOptionValues(option)
+
+	switch option.Type {
+	case models.TypeSelector, models.TypeOptionalSelector:
+		if !answered {
+			if len(values) == 1 {
+				return values[0], nil
+			}
+			return "", fmt.Errorf("no answer for scanner option %s, available values: %s", optionName(option), strings.Join(values, ", "))
+		}
+		if _, ok := option.ChildOptionMap[value]; !ok && option.Type == models.TypeSelector {
+			return "", fmt.Errorf("invalid answer for scanner option %s: %s, available values: %s", optionName(option), value, strings.Join(values, ", "))
+		}
+		return value, nil
+	case models.TypeUserInput, models.TypeOptionalUserInput:
+		if !answered {
+			if option.Type == models.TypeOptionalUserInput {
+				return "", nil
+			}
+			for _, value := range values {
+				if value != models.UserInputOptionDefaultValue {
+					// the scanner's suggested value
+					return value, nil
+				}
+			}
+			return "", fmt.Errorf("no answer for scanner option %s", optionName(option))
+		}
+		return strings.TrimSpace(value), nil
+	}
+
+	return "", fmt.Errorf("invalid type (%s) of scanner option %s", option.Type, optionName(option))
+}
+
+// resolveScannerOptions walks the option tree the same way as the scanner's prompts,
+// taking the values from the answers, and returns the selected config's name and the app envs.
+func resolveScannerOptions(root models.OptionNode, answers ScannerAnswers, used map[string]bool) (string, []envmanModels.EnvironmentItemModel, error) {
+	var appEnvs []envmanModels.EnvironmentItemModel
+
+	option := &root
+	for option != nil {
+		if option.Config != "" {
+			return option.Config, appEnvs, nil
+		}
+
+		value, err := optionValue(*option, answers, used)
+		if err != nil {
+			return "", nil, err
+		}
+		if option.EnvKey != "" {
+			appEnvs = append(appEnvs, envmanModels.EnvironmentItemModel{option.EnvKey: value})
+		}
+
+		next, ok := option.ChildOptionMap[value]
+		if len(option.ChildOptionMap) == 1 || (!ok && option.Type != models.TypeSelector) {
+			// the next option does not depend on the value
+			values := sortedOptionValues(*option)
+			if len(values) == 0 {
+				break
+			}
+			next = option.ChildOptionMap[values[0]]
+		}
+		option = next
+	}
+
+	return "", nil, fmt.Errorf("no config found for the scanner answers")
+}
+
+// resolveScannerConfig selects the config of the scan result based on the answers, without prompting the user.
+func resolveScannerConfig(scanResult models.ScanResultModel, answers ScannerAnswers) (bitriseModels.BitriseDataModel, error) {
+	var platforms []string
+	for platform := range scanResult.ScannerToOptionRoot {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	used := map[string]bool{}
+	var platform string
+	switch {
+	case len(platforms) == 0:
+		return bitriseModels.BitriseDataModel{}, fmt.Errorf("no platform detected")
+	case answers[ScannerPlatformAnswerKey] != "":
+		platform = answers[ScannerPlatformAnswerKey]
+		used[ScannerPlatformAnswerKey] = true
+		if _, ok := scanResult.ScannerToOptionRoot[platform]; !ok {
+			return bitriseModels.BitriseDataModel{}, fmt.Errorf("invalid %s answer: %s, detected platforms: %s", ScannerPlatformAnswerKey, platform, strings.Join(platforms, ", "))
+		}
+	case len(platforms) == 1:
+		platform = platforms[0]
+	default:
+		return bitriseModels.BitriseDataModel{}, fmt.Errorf("multiple platforms detected (%s), select one with the %s answer", strings.Join(platforms, ", "), ScannerPlatformAnswerKey)
+	}
+
+	configName, appEnvs, err := resolveScannerOptions(scanResult.ScannerToOptionRoot[platform], answers, used)
+	if err != nil {
+		return bitriseModels.BitriseDataModel{}, err
+	}
+
+	var unmatched []string
+	for key := range answers {
+		if !used[key] {
+			unmatched = append(unmatched, key)
+		}
+	}
+	if len(unmatched) > 0 {
+		sort.Strings(unmatched)
+		return bitriseModels.BitriseDataModel{}, fmt.Errorf("scanner answers do not match any option of the %s project: %s", platform, strings.Join(unmatched, ", "))
+	}
+
+	configStr, ok := scanResult.ScannerToBitriseConfigMap[platform][configName]
+	if !ok {
+		return bitriseModels.BitriseDataModel{}, fmt.Errorf("config (%s) not found in the scan result", configName)
+	}
+
+	var config bitriseModels.BitriseDataModel
+	if err := yaml.Unmarshal([]byte(configStr), &config); err != nil {
+		return bitriseModels.BitriseDataModel{}, fmt.Errorf("failed to unmarshal config, error: %s", err)
+	}
+	config.App.Environments = append(config.App.Environments, appEnvs...)
+
+	return config, nil
+}
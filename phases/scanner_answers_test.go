@@ -0,0 +1,134 @@
+package phases
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/bitrise-init/models"
+	envmanModels "github.com/bitrise-io/envman/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func testScanResult() models.ScanResultModel {
+	iosRoot := models.NewOption("Project or Workspace path", "", "BITRISE_PROJECT_PATH", models.TypeSelector)
+	for _, project := range []string{"App.xcodeproj", "Other.xcworkspace"} {
+		scheme := models.NewOption("Scheme name", "", "BITRISE_SCHEME", models.TypeSelector)
+		iosRoot.AddOption(project, scheme)
+
+		exportMethod := models.NewOption("Distribution method", "", "BITRISE_DISTRIBUTION_METHOD", models.TypeSelector)
+		scheme.AddOption("App", exportMethod)
+		for _, method := range []string{"ad-hoc", "app-store"} {
+			exportMethod.AddConfig(method, models.NewConfigOption("ios-config", nil))
+		}
+	}
+
+	androidRoot := models.NewOption("The root directory of an Android project", "", "PROJECT_LOCATION", models.TypeSelector)
+	module := models.NewOption("Module", "", "MODULE", models.TypeUserInput)
+	androidRoot.AddOption(".", module)
+	variant := models.NewOption("Variant", "", "VARIANT", models.TypeOptionalUserInput)
+	module.AddOption(models.UserInputOptionDefaultValue, variant)
+	variant.AddConfig(models.UserInputOptionDefaultValue, models.NewConfigOption("android-config", nil))
+
+	return models.ScanResultModel{
+		ScannerToOptionRoot: map[string]models.OptionNode{
+			"ios":     *iosRoot,
+			"android": *androidRoot,
+		},
+		ScannerToBitriseConfigMap: map[string]models.BitriseConfigMap{
+			"ios":     {"ios-config": "format_version: \"11\"\nproject_type: ios\nworkflows:\n  primary: {}\n"},
+			"android": {"android-config": "format_version: \"11\"\nproject_type: android\nworkflows:\n  primary: {}\n"},
+		},
+	}
+}
+
+func Test_resolveScannerConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		answers         ScannerAnswers
+		wantProjectType string
+		wantEnvs        []envmanModels.EnvironmentItemModel
+		wantErr         string
+	}{
+		{
+			name: "answers by env key",
+			answers: ScannerAnswers{
+				"platform":                    "ios",
+				"BITRISE_PROJECT_PATH":        "Other.xcworkspace",
+				"BITRISE_DISTRIBUTION_METHOD": "app-store",
+			},
+			wantProjectType: "ios",
+			wantEnvs: []envmanModels.EnvironmentItemModel{
+				{"BITRISE_PROJECT_PATH": "Other.xcworkspace"},
+				{"BITRISE_SCHEME": "App"},
+				{"BITRISE_DISTRIBUTION_METHOD": "app-store"},
+			},
+		},
+		{
+			name: "answers by title and user input",
+			answers: ScannerAnswers{
+				"platform": "android",
+				"Module":   "app",
+			},
+			wantProjectType: "android",
+			wantEnvs: []envmanModels.EnvironmentItemModel{
+				{"PROJECT_LOCATION": "."},
+				{"MODULE": "app"},
+				{"VARIANT": ""},
+			},
+		},
+		{
+			name:    "multiple platforms",
+			answers: ScannerAnswers{"MODULE": "app"},
+			wantErr: "multiple platforms detected (android, ios), select one with the platform answer",
+		},
+		{
+			name:    "missing selector answer",
+			answers: ScannerAnswers{"platform": "ios"},
+			wantErr: "no answer for scanner option Project or Workspace path (BITRISE_PROJECT_PATH), available values: App.xcodeproj, Other.xcworkspace",
+		},
+		{
+			name:    "invalid selector answer",
+			answers: ScannerAnswers{"platform": "ios", "BITRISE_PROJECT_PATH": "Missing.xcodeproj"},
+			wantErr: "invalid answer for scanner option Project or Workspace path (BITRISE_PROJECT_PATH): Missing.xcodeproj, available values: App.xcodeproj, Other.xcworkspace",
+		},
+		{
+			name:    "missing user input answer",
+			answers: ScannerAnswers{"platform": "android"},
+			wantErr: "no answer for scanner option Module (MODULE)",
+		},
+		{
+			name:    "unmatched answer",
+			answers: ScannerAnswers{"platform": "android", "MODULE": "app", "BITRISE_SCHEME": "App"},
+			wantErr: "scanner answers do not match any option of the android project: BITRISE_SCHEME",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveScannerConfig(testScanResult(), tt.answers)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantProjectType, got.ProjectType)
+			require.Equal(t, tt.wantEnvs, got.App.Environments)
+		})
+	}
+}
+
+func TestParseScannerAnswers(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "answers.yml")
+	require.NoError(t, os.WriteFile(pth, []byte("platform: ios\nBITRISE_SCHEME: App\n"), 0600))
+
+	answers, err := ParseScannerAnswers([]string{"BITRISE_SCHEME=App Tests", "BITRISE_PROJECT_PATH=App.xcodeproj"}, pth)
+	require.NoError(t, err)
+	require.Equal(t, ScannerAnswers{
+		"platform":             "ios",
+		"BITRISE_SCHEME":       "App Tests",
+		"BITRISE_PROJECT_PATH": "App.xcodeproj",
+	}, answers)
+
+	_, err = ParseScannerAnswers([]string{"BITRISE_SCHEME"}, "")
+	require.EqualError(t, err, "invalid scanner answer (BITRISE_SCHEME), expected format: KEY=VALUE")
+}
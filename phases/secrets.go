@@ -0,0 +1,130 @@
+package phases
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/bitrise-add-new-project/bitriseio"
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/manifoldco/promptui"
+)
+
+// Secret is an app secret created after the registration.
+type Secret struct {
+	Name string
+	bitriseio.SecretParams
+}
+
+func askSecretValue(name string) (string, error) {
+	prompt := promptui.Prompt{
+		Label: fmt.Sprintf("Value of %s (leave empty to skip)", name),
+		Mask:  '*',
+	}
+
+	value, err := prompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("prompt user: %s", err)
+	}
+	return value, nil
+}
+
+func isRegisteredSecret(name string, secrets []Secret) bool {
+	for _, secret := range secrets {
+		if secret.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// secretValues returns the values of the secrets from the dotenv file, then asks for the values of the missing ones.
+// The secrets of the file are all kept, even if the bitrise.yml does not reference them.
+func secretValues(missing []string, fileSecrets []envVar, ask func(name string) (string, error)) ([]envVar, error) {
+	values := fileSecrets
+
+	var fileKeys []string
+	for _, secret := range fileSecrets {
+		fileKeys = append(fileKeys, secret.Key)
+	}
+
+	for _, name := range missing {
+		if sliceutil.IsStringInSlice(name, fileKeys) {
+			continue
+		}
+
+		value, err := ask(name)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			log.Warnf("Skipping %s, add it on the Secrets tab of the app later.", name)
+			continue
+		}
+		values = append(values, envVar{Key: name, Value: value})
+	}
+	return values, nil
+}
+
+// Secrets collects the values of the env vars referenced by the bitrise.yml which are neither defined in it nor provided by Bitrise,
+// they are created as app secrets, otherwise the first build would fail. The values are read from the dotenv file if given.
+// The already registered secrets (e.g. the app env vars) are not asked for.
+func Secrets(bitriseYML models.BitriseDataModel, projectType string, secretsFile string, registered []Secret) ([]Secret, error) {
+	var missing []string
+	for _, name := range undefinedEnvVars(bitriseYML, projectType) {
+		if !isRegisteredSecret(name, registered) {
+			missing = append(missing, name)
+		}
+	}
+
+	var fileSecrets []envVar
+	if secretsFile != "" {
+		var err error
+		if fileSecrets, err = readDotenv(secretsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(missing) == 0 && len(fileSecrets) == 0 {
+		return nil, nil
+	}
+
+	fmt.Println()
+	log.Infof("SECRETS")
+	if len(missing) > 0 {
+		log.Printf("The bitrise.yml references env vars which are not defined: %s", colorstring.Yellow(strings.Join(missing, ", ")))
+		log.Printf("Values of env vars exported by previous steps can be skipped.")
+	}
+
+	values, err := secretValues(missing, fileSecrets, askSecretValue)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	protected, err := askYesNo("Do you want to protect the secrets? (protected values can not be viewed or changed later)", "Protect secrets")
+	if err != nil {
+		return nil, err
+	}
+	exposed, err := askYesNo("Do you want to expose the secrets to pull request builds? (builds of pull requests from forks can read them)", "Expose secrets to pull requests")
+	if err != nil {
+		return nil, err
+	}
+
+	var secrets []Secret
+	for _, value := range values {
+		secrets = append(secrets, Secret{
+			Name: value.Key,
+			SecretParams: bitriseio.SecretParams{
+				Value:                    value.Value,
+				IsProtected:              protected,
+				IsExposedForPullRequests: exposed,
+			},
+		})
+	}
+	return secrets, nil
+}
@@ -0,0 +1,55 @@
+package phases
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_undefinedEnvVars(t *testing.T) {
+	const config = `format_version: "13"
+app:
+  envs:
+  - BITRISE_PROJECT_PATH: App.xcodeproj
+workflows:
+  primary:
+    envs:
+    - CHANNEL: "#builds"
+    steps:
+    - xcode-archive@5:
+        inputs:
+        - project_path: $BITRISE_PROJECT_PATH
+        - api_key: $APP_STORE_CONNECT_KEY
+    - slack@4:
+        inputs:
+        - webhook_url: $SLACK_WEBHOOK
+        - channel: $CHANNEL
+        - message: Build $BITRISE_BUILD_NUMBER of ${BITRISE_APP_TITLE}
+`
+	var bitriseYML models.BitriseDataModel
+	require.NoError(t, yaml.Unmarshal([]byte(config), &bitriseYML))
+
+	require.Equal(t, []string{"APP_STORE_CONNECT_KEY", "SLACK_WEBHOOK"}, undefinedEnvVars(bitriseYML, "ios"))
+}
+
+func Test_secretValues(t *testing.T) {
+	var asked []string
+	ask := func(name string) (string, error) {
+		asked = append(asked, name)
+		if name == "SKIPPED" {
+			return "", nil
+		}
+		return "typed", nil
+	}
+
+	values, err := secretValues([]string{"FROM_FILE", "SKIPPED", "TYPED"}, []envVar{{Key: "FROM_FILE", Value: "file"}, {Key: "EXTRA", Value: "extra"}}, ask)
+	require.NoError(t, err)
+	require.Equal(t, []string{"SKIPPED", "TYPED"}, asked)
+	require.Equal(t, []envVar{
+		{Key: "FROM_FILE", Value: "file"},
+		{Key: "EXTRA", Value: "extra"},
+		{Key: "TYPED", Value: "typed"},
+	}, values)
+}
@@ -2,37 +2,113 @@ package phases
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 
+	"github.com/bitrise-io/bitrise-add-new-project/bitriseio"
 	"github.com/bitrise-io/go-utils/colorstring"
 	"github.com/bitrise-io/go-utils/log"
 	"github.com/manifoldco/promptui"
 )
 
-// Based on: https://github.com/bitrise-io/bitrise-website/blob/master/config/available_stacks.yml
 const (
-	defaultMacOSStack = "osx-xcode-16.0.x"
-	defaultLinuxStack = "linux-docker-android-22.04"
+	stackOSMacOS = bitriseio.StackOSMacOS
+	stackOSLinux = bitriseio.StackOSLinux
 )
 
-var defaultStacks = map[string]string{
-	"ios":                  defaultMacOSStack,
-	"macos":                defaultMacOSStack,
-	"android":              defaultLinuxStack,
-	"cordova":              defaultMacOSStack,
-	"ionic":                defaultMacOSStack,
-	"react-native":         defaultMacOSStack,
-	"flutter":              defaultMacOSStack,
-	"node-js":              defaultLinuxStack,
-	"kotlin-multiplatform": defaultMacOSStack,
-	"java":                 defaultLinuxStack,
-	"other":                defaultLinuxStack,
+// Fallback defaults, used only if no matching stack is found in the available stacks.
+// Based on: https://github.com/bitrise-io/bitrise-website/blob/master/config/available_stacks.yml
+var fallbackStacks = map[string]string{
+	stackOSMacOS: "osx-xcode-16.0.x",
+	stackOSLinux: "linux-docker-android-22.04",
 }
 
-type availableStacksResponse map[string]interface{}
+var (
+	// e.g. osx-xcode-16.2.x
+	xcodeStackPattern = regexp.MustCompile(`^osx-xcode-(\d+)\.(\d+)\.x$`)
+	// e.g. linux-docker-android-22.04, ubuntu-noble-24.04-bitrise-2025-android
+	androidStackPattern = regexp.MustCompile(`^(?:linux-docker-android|ubuntu-[a-z]+)-(\d+)\.(\d+)(?:-[a-z0-9-]+)?$`)
+)
+
+// latestStack returns the stable stack with the highest major.minor version matching the pattern,
+// the pattern's first two submatches are the major and minor version. Edge and deprecated stacks are skipped.
+func latestStack(availableStacks []availableStack, pattern *regexp.Regexp) string {
+	var (
+		latest      string
+		latestMajor = -1
+		latestMinor = -1
+	)
+	for _, availableStack := range availableStacks {
+		if availableStack.Status != stackStatusStable {
+			continue
+		}
+		stack := availableStack.ID
+		match := pattern.FindStringSubmatch(stack)
+		if match == nil {
+			continue
+		}
+		major, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		minor, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+		if major > latestMajor || (major == latestMajor && minor > latestMinor) || (major == latestMajor && minor == latestMinor && stack > latest) {
+			latest, latestMajor, latestMinor = stack, major, minor
+		}
+	}
+	return latest
+}
+
+// defaultStackOS returns the OS of the default stack for the project,
+// the OS required by the workflow's steps takes precedence over the project type's default.
+func defaultStackOS(projectType string, stepIDs []string) string {
+	if stackOS := requiredStackOS(projectType, stepIDs); stackOS != "" {
+		return stackOS
+	}
+	// the user selects the stack of unknown project types
+	projectTypeConfig, _ := bitriseio.LookupProjectType(projectType)
+	return projectTypeConfig.StackOS
+}
 
-func fetchAvailableStacks(orgSlug string, apiToken string) ([]string, error) {
+// defaultStack returns the default stack for the OS from the available stacks:
+// the latest stable Xcode stack for macOS, the latest Ubuntu Android stack for Linux.
+func defaultStack(stackOS string, availableStacks []availableStack) string {
+	if stackOS == "" {
+		return ""
+	}
+
+	var stack string
+	if stackOS == stackOSMacOS {
+		stack = latestStack(availableStacks, xcodeStackPattern)
+	} else {
+		var androidStacks []availableStack
+		for _, availableStack := range availableStacks {
+			if strings.Contains(availableStack.ID, "android") {
+				androidStacks = append(androidStacks, availableStack)
+			}
+		}
+		stack = latestStack(androidStacks, androidStackPattern)
+	}
+	if stack != "" {
+		return stack
+	}
+
+	if fallback, ok := findStack(availableStacks, fallbackStacks[stackOS]); ok && fallback.Status == stackStatusStable {
+		return fallback.ID
+	}
+	return ""
+}
+
+type availableStacksResponse map[string]json.RawMessage
+
+func fetchAvailableStacks(orgSlug string, apiToken string) ([]availableStack, error) {
 	var url string
 	if orgSlug != "" {
 		url = fmt.Sprintf("https://api.bitrise.io/v0.1/organizations/%s/available-stacks", orgSlug)
@@ -50,6 +126,11 @@ func fetchAvailableStacks(orgSlug string, apiToken string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			log.Debugf("Failed to close response body: %s", err)
+		}
+	}()
 
 	if res.StatusCode != 200 {
 		return nil, fmt.Errorf("server response: %s", res.Status)
@@ -60,49 +141,79 @@ func fetchAvailableStacks(orgSlug string, apiToken string) ([]string, error) {
 		return nil, err
 	}
 
-	keys := make([]string, 0, len(jsonMap))
-	for key := range jsonMap {
-		keys = append(keys, key)
+	return parseAvailableStacks(jsonMap), nil
+}
+
+// checkStackCompatibility prints the incompatibilities of the stack with the project type and the workflow's steps,
+// returning an error with a suggested stack if the build would fail on the stack.
+func checkStackCompatibility(projectType string, stepIDs []string, stack availableStack, availableStacks []availableStack) error {
+	var blocking []stackIncompatibility
+	for _, issue := range stackIncompatibilities(projectType, stepIDs, stack.OS) {
+		if issue.blocking {
+			blocking = append(blocking, issue)
+			continue
+		}
+		log.Warnf("The %s stack might not be suitable: %s", stack.ID, issue.message)
 	}
 
-	return keys, nil
+	if len(blocking) == 0 {
+		return nil
+	}
+
+	var messages []string
+	for _, issue := range blocking {
+		messages = append(messages, issue.message)
+	}
+	err := fmt.Sprintf("the %s stack is not compatible with the project: %s", stack.ID, strings.Join(messages, ", "))
+	if suggested := defaultStack(blocking[0].requiredOS, availableStacks); suggested != "" {
+		err += fmt.Sprintf(" (suggested stack: %s)", suggested)
+	}
+	return errors.New(err)
 }
 
 // Stack returns the selected stack for the project or an error
 // if something went wrong during stack autodetection.
-func Stack(orgSlug string, apiToken string, projectType string) (string, error) {
+// If the stack is provided, it is only validated against the stacks available to the account
+// and the project type and steps of the workflow.
+func Stack(orgSlug string, apiToken string, projectType string, stack string, stepIDs []string) (string, error) {
 	fmt.Println()
 	log.Infof("SELECT STACK")
-	stack := defaultStacks[projectType]
 
 	availableStacks, err := fetchAvailableStacks(orgSlug, apiToken)
 	if err != nil {
 		return "", fmt.Errorf("Failed to fetch available stacks: %s", err)
 	}
 
-	if stack == "" {
-		log.Warnf("Could not identify default stack for project. Falling back to manual stack selection.")
-
-		prompt := promptui.Select{
-			Label: "Please choose from the available stacks",
-			Items: availableStacks,
-			Templates: &promptui.SelectTemplates{
-				Selected: "Stack: {{ . | green }}",
-			},
+	if stack != "" {
+		selected, ok := findStack(availableStacks, stack)
+		if !ok {
+			return "", fmt.Errorf("stack (%s) is not available for the account, available stacks: %s", stack, strings.Join(stackIDs(availableStacks), ", "))
 		}
-
-		_, stack, err = prompt.Run()
-		if err != nil {
-			return "", fmt.Errorf("scan user input: %s", err)
+		if selected.Status == stackStatusDeprecated {
+			log.Warnf("The %s stack is deprecated.", stack)
 		}
-
+		if err := checkStackCompatibility(projectType, stepIDs, selected, availableStacks); err != nil {
+			return "", err
+		}
+		log.Donef(colorstring.Greenf("Selected stack: ") + stack)
 		return stack, nil
 	}
 
-	systemReportURL := fmt.Sprintf("https://github.com/bitrise-io/bitrise.io/blob/master/system_reports/%s.log", stack)
+	stack = defaultStack(defaultStackOS(projectType, stepIDs), availableStacks)
+	if stack == "" {
+		log.Warnf("Could not identify default stack for project. Falling back to manual stack selection.")
+
+		return selectCompatibleStack("Please choose from the available stacks", projectType, stepIDs, availableStacks)
+	}
+
 	log.Printf("Project type: %s", colorstring.Green(projectType))
 	log.Printf("Default stack for your project type: %s", colorstring.Green(stack))
-	log.Printf("You can check the preinstalled tools at: %s", systemReportURL)
+	log.Printf("You can check the preinstalled tools at: %s", systemReportURL(stack))
+	if selected, ok := findStack(availableStacks, stack); ok {
+		if err := checkStackCompatibility(projectType, stepIDs, selected, availableStacks); err != nil {
+			log.Warnf("%s", err)
+		}
+	}
 
 	const (
 		optionYes = "Yes"
@@ -127,17 +238,23 @@ func Stack(orgSlug string, apiToken string, projectType string) (string, error)
 		return stack, nil
 	}
 
-	stackPrompt := promptui.Select{
-		Label: "Choose stack",
-		Items: availableStacks,
-		Templates: &promptui.SelectTemplates{
-			Selected: "Stack: {{ . | green }}",
-		},
-	}
-	_, stack, err = stackPrompt.Run()
-	if err != nil {
-		return "", fmt.Errorf("user input: %s", err)
-	}
+	return selectCompatibleStack("Choose stack", projectType, stepIDs, availableStacks)
+}
 
-	return stack, nil
+// selectCompatibleStack asks for a stack until one compatible with the project is selected.
+func selectCompatibleStack(label string, projectType string, stepIDs []string, availableStacks []availableStack) (string, error) {
+	for {
+		stack, err := selectStack(label, availableStacks)
+		if err != nil {
+			return "", fmt.Errorf("scan user input: %s", err)
+		}
+
+		selected, _ := findStack(availableStacks, stack)
+		if err := checkStackCompatibility(projectType, stepIDs, selected, availableStacks); err != nil {
+			log.Errorf("%s", err)
+			continue
+		}
+
+		return stack, nil
+	}
 }
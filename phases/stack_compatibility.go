@@ -0,0 +1,196 @@
+package phases
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/bitrise-add-new-project/bitriseio"
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/bitrise-io/stepman/stepid"
+)
+
+const defaultStepLibSourceURL = "https://github.com/bitrise-io/bitrise-steplib.git"
+
+// Steps which can only run on the given stack OS.
+var stackOSByStepID = map[string]string{
+	"xcode-archive":                     stackOSMacOS,
+	"xcode-archive-mac":                 stackOSMacOS,
+	"xcode-test":                        stackOSMacOS,
+	"xcode-test-mac":                    stackOSMacOS,
+	"xcode-analyze":                     stackOSMacOS,
+	"xcode-build-for-simulator":         stackOSMacOS,
+	"xcode-build-for-test":              stackOSMacOS,
+	"xcode-test-without-building":       stackOSMacOS,
+	"xcode-start-simulator":             stackOSMacOS,
+	"export-xcarchive":                  stackOSMacOS,
+	"certificate-and-profile-installer": stackOSMacOS,
+	"manage-ios-code-signing":           stackOSMacOS,
+	"cocoapods-install":                 stackOSMacOS,
+	"carthage":                          stackOSMacOS,
+	"xcparse":                           stackOSMacOS,
+	"avd-manager":                       stackOSLinux,
+	"wait-for-android-emulator":         stackOSLinux,
+}
+
+// workflowChain returns the workflow with its before_run and after_run workflows in execution order.
+func workflowChain(bitriseYML models.BitriseDataModel, workflowID string) []string {
+	var chain []string
+	var visit func(id string, stack []string)
+	visit = func(id string, stack []string) {
+		if sliceutil.IsStringInSlice(id, stack) {
+			return
+		}
+		workflow, ok := bitriseYML.Workflows[id]
+		if !ok {
+			return
+		}
+		stack = append(stack, id)
+		for _, before := range workflow.BeforeRun {
+			visit(before, stack)
+		}
+		chain = append(chain, id)
+		for _, after := range workflow.AfterRun {
+			visit(after, stack)
+		}
+	}
+	visit(workflowID, nil)
+	return chain
+}
+
+// stepIDFromKey returns the ID of the step referenced in a step list, e.g. xcode-archive for xcode-archive@5
+// or git::https://github.com/bitrise-steplib/steps-xcode-archive.git@master.
+func stepIDFromKey(key, defaultStepLibSource string) string {
+	if defaultStepLibSource == "" {
+		defaultStepLibSource = defaultStepLibSourceURL
+	}
+	canonicalID, err := stepid.CreateCanonicalIDFromString(key, defaultStepLibSource)
+	if err != nil {
+		return key
+	}
+
+	id := canonicalID.IDorURI
+	if strings.Contains(id, "/") {
+		id = strings.TrimSuffix(path.Base(id), ".git")
+		id = strings.TrimPrefix(id, "steps-")
+		id = strings.TrimPrefix(id, "bitrise-step-")
+	}
+	return id
+}
+
+func bundleStepIDs(bitriseYML models.BitriseDataModel, bundleID string, visited []string) []string {
+	if sliceutil.IsStringInSlice(bundleID, visited) {
+		return nil
+	}
+	visited = append(visited, bundleID)
+
+	var ids []string
+	for _, item := range bitriseYML.StepBundles[bundleID].Steps {
+		key, t, err := item.GetKeyAndType()
+		if err != nil {
+			continue
+		}
+		switch t {
+		case models.StepListItemTypeStep:
+			ids = append(ids, stepIDFromKey(key, bitriseYML.DefaultStepLibSource))
+		case models.StepListItemTypeBundle:
+			ids = append(ids, bundleStepIDs(bitriseYML, key, visited)...)
+		}
+	}
+	return ids
+}
+
+// WorkflowStepIDs returns the IDs of the steps run by the workflow, including the before_run and after_run workflows.
+func WorkflowStepIDs(bitriseYML models.BitriseDataModel, workflowID string) []string {
+	var ids []string
+	for _, id := range workflowChain(bitriseYML, workflowID) {
+		for _, item := range bitriseYML.Workflows[id].Steps {
+			key, t, err := item.GetKeyAndType()
+			if err != nil {
+				continue
+			}
+			switch t {
+			case models.StepListItemTypeStep:
+				ids = append(ids, stepIDFromKey(key, bitriseYML.DefaultStepLibSource))
+			case models.StepListItemTypeBundle:
+				ids = append(ids, bundleStepIDs(bitriseYML, key, nil)...)
+			case models.StepListItemTypeWith:
+				with, err := item.GetWith()
+				if err != nil {
+					continue
+				}
+				for _, step := range with.Steps {
+					if stepKey, _, err := step.GetStepIDAndStep(); err == nil {
+						ids = append(ids, stepIDFromKey(stepKey, bitriseYML.DefaultStepLibSource))
+					}
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// stackIncompatibility is a reason why the project might not build on a stack.
+type stackIncompatibility struct {
+	requiredOS string
+	message    string
+	// blocking incompatibilities make the build fail for sure
+	blocking bool
+}
+
+// requiredStackOS returns the stack OS required by the project type or the steps, or an empty string if any OS works.
+func requiredStackOS(projectType string, stepIDs []string) string {
+	if stackOS := bitriseio.GetProjectType(projectType).RequiredStackOS; stackOS != "" {
+		return stackOS
+	}
+	for _, id := range stepIDs {
+		if stackOS, ok := stackOSByStepID[id]; ok {
+			return stackOS
+		}
+	}
+	return ""
+}
+
+// stackIncompatibilities checks the project type and the steps of the workflow against the stack OS.
+func stackIncompatibilities(projectType string, stepIDs []string, stackOS string) []stackIncompatibility {
+	var issues []stackIncompatibility
+
+	if required := bitriseio.GetProjectType(projectType).RequiredStackOS; required != "" && required != stackOS {
+		issues = append(issues, stackIncompatibility{
+			requiredOS: required,
+			message:    fmt.Sprintf("%s projects can only be built on %s stacks", projectType, required),
+			blocking:   true,
+		})
+	}
+
+	incompatibleSteps := map[string][]string{}
+	for _, id := range stepIDs {
+		if required, ok := stackOSByStepID[id]; ok && required != stackOS && !sliceutil.IsStringInSlice(id, incompatibleSteps[required]) {
+			incompatibleSteps[required] = append(incompatibleSteps[required], id)
+		}
+	}
+	var requiredOSs []string
+	for required := range incompatibleSteps {
+		requiredOSs = append(requiredOSs, required)
+	}
+	sort.Strings(requiredOSs)
+	for _, required := range requiredOSs {
+		issues = append(issues, stackIncompatibility{
+			requiredOS: required,
+			message:    fmt.Sprintf("the workflow uses steps which only run on %s stacks: %s", required, strings.Join(incompatibleSteps[required], ", ")),
+			// Steps requiring macOS fail on Linux, Linux only steps might have macOS alternatives.
+			blocking: required == stackOSMacOS,
+		})
+	}
+
+	if stackOS == stackOSLinux && bitriseio.GetProjectType(projectType).CrossPlatform && len(issues) == 0 {
+		issues = append(issues, stackIncompatibility{
+			requiredOS: stackOSMacOS,
+			message:    fmt.Sprintf("%s projects need a %s stack to build the iOS app", projectType, stackOSMacOS),
+		})
+	}
+
+	return issues
+}
@@ -0,0 +1,76 @@
+package phases
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/bitrise/v2/bitrise"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkflowStepIDs(t *testing.T) {
+	bitriseYML, _, err := bitrise.ConfigModelFromYAMLBytes([]byte(`format_version: "13"
+default_step_lib_source: https://github.com/bitrise-io/bitrise-steplib.git
+workflows:
+  _setup:
+    steps:
+    - git-clone@8: {}
+  primary:
+    before_run:
+    - _setup
+    steps:
+    - git::https://github.com/bitrise-steplib/steps-certificate-and-profile-installer.git@master: {}
+    - xcode-archive@5:
+        inputs:
+        - scheme: App
+`))
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"git-clone", "certificate-and-profile-installer", "xcode-archive"}, WorkflowStepIDs(bitriseYML, "primary"))
+}
+
+func Test_stackIncompatibilities(t *testing.T) {
+	tests := []struct {
+		name         string
+		projectType  string
+		stepIDs      []string
+		stackOS      string
+		wantBlocking []bool
+	}{
+		{
+			name:        "ios on macOS",
+			projectType: "ios",
+			stepIDs:     []string{"xcode-archive"},
+			stackOS:     stackOSMacOS,
+		},
+		{
+			name:         "ios on Linux",
+			projectType:  "ios",
+			stepIDs:      []string{"xcode-archive"},
+			stackOS:      stackOSLinux,
+			wantBlocking: []bool{true, true},
+		},
+		{
+			name:         "flutter on Linux",
+			projectType:  "flutter",
+			stepIDs:      []string{"flutter-build"},
+			stackOS:      stackOSLinux,
+			wantBlocking: []bool{false},
+		},
+		{
+			name:         "emulator step on macOS",
+			projectType:  "android",
+			stepIDs:      []string{"avd-manager"},
+			stackOS:      stackOSMacOS,
+			wantBlocking: []bool{false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var blocking []bool
+			for _, issue := range stackIncompatibilities(tt.projectType, tt.stepIDs, tt.stackOS) {
+				blocking = append(blocking, issue.blocking)
+			}
+			require.Equal(t, tt.wantBlocking, blocking)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package phases
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+)
+
+const (
+	stackStatusStable     = "stable"
+	stackStatusEdge       = "edge"
+	stackStatusDeprecated = "deprecated"
+)
+
+// availableStack is an item of the available-stacks response.
+type availableStack struct {
+	ID     string `json:"-"`
+	Title  string `json:"title"`
+	OS     string `json:"os"`
+	Status string `json:"status"`
+}
+
+var versionPartPattern = regexp.MustCompile(`\d+`)
+
+func (s availableStack) version() []int {
+	var version []int
+	for _, part := range versionPartPattern.FindAllString(s.ID, -1) {
+		if n, err := strconv.Atoi(part); err == nil {
+			version = append(version, n)
+		}
+	}
+	return version
+}
+
+// osName returns the human readable name of the stack's OS.
+func (s availableStack) osName() string {
+	switch s.OS {
+	case stackOSMacOS:
+		return "macOS"
+	case stackOSLinux:
+		return "Linux"
+	}
+	return s.OS
+}
+
+// Label is the text of the stack in the picker.
+func (s availableStack) Label() string {
+	label := fmt.Sprintf("[%s] %s", s.osName(), s.ID)
+	if s.Title != "" && s.Title != s.ID {
+		label += " - " + s.Title
+	}
+	if s.Status == stackStatusDeprecated || s.Status == stackStatusEdge {
+		label += fmt.Sprintf(" (%s)", s.Status)
+	}
+	return label
+}
+
+// SystemReportURL lists the tools preinstalled on the stack.
+func (s availableStack) SystemReportURL() string {
+	return systemReportURL(s.ID)
+}
+
+func systemReportURL(stack string) string {
+	return fmt.Sprintf("https://github.com/bitrise-io/bitrise.io/blob/master/system_reports/%s.log", stack)
+}
+
+func stackOSFromID(id string) string {
+	if strings.HasPrefix(id, "osx-") || strings.HasPrefix(id, "macos-") {
+		return stackOSMacOS
+	}
+	return stackOSLinux
+}
+
+// parseAvailableStacks decodes the stack metadata of the available-stacks response,
+// missing OS and status are derived from the stack ID.
+func parseAvailableStacks(response map[string]json.RawMessage) []availableStack {
+	var stacks []availableStack
+	for id, raw := range response {
+		var stack availableStack
+		if err := json.Unmarshal(raw, &stack); err != nil {
+			stack = availableStack{}
+		}
+		stack.ID = id
+
+		stack.OS = strings.ToLower(stack.OS)
+		switch {
+		case strings.Contains(stack.OS, "mac") || strings.Contains(stack.OS, "osx"):
+			stack.OS = stackOSMacOS
+		case strings.Contains(stack.OS, "linux") || strings.Contains(stack.OS, "ubuntu"):
+			stack.OS = stackOSLinux
+		case stack.OS == "":
+			stack.OS = stackOSFromID(id)
+		}
+
+		stack.Status = strings.ToLower(stack.Status)
+		if stack.Status == "" {
+			if strings.Contains(id, stackStatusEdge) {
+				stack.Status = stackStatusEdge
+			} else {
+				stack.Status = stackStatusStable
+			}
+		}
+
+		stacks = append(stacks, stack)
+	}
+
+	sortStacks(stacks)
+	return stacks
+}
+
+// sortStacks groups the stacks by OS, then orders them by version descending with deprecated stacks last.
+func sortStacks(stacks []availableStack) {
+	sort.SliceStable(stacks, func(i, j int) bool {
+		a, b := stacks[i], stacks[j]
+		if a.OS != b.OS {
+			return a.OS > b.OS // macOS first
+		}
+		if aDeprecated, bDeprecated := a.Status == stackStatusDeprecated, b.Status == stackStatusDeprecated; aDeprecated != bDeprecated {
+			return bDeprecated
+		}
+
+		aVersion, bVersion := a.version(), b.version()
+		for k := 0; k < len(aVersion) && k < len(bVersion); k++ {
+			if aVersion[k] != bVersion[k] {
+				return aVersion[k] > bVersion[k]
+			}
+		}
+		if len(aVersion) != len(bVersion) {
+			return len(aVersion) > len(bVersion)
+		}
+		return a.ID < b.ID
+	})
+}
+
+func stackIDs(stacks []availableStack) []string {
+	ids := make([]string, 0, len(stacks))
+	for _, stack := range stacks {
+		ids = append(ids, stack.ID)
+	}
+	return ids
+}
+
+func findStack(stacks []availableStack, id string) (availableStack, bool) {
+	for _, stack := range stacks {
+		if stack.ID == id {
+			return stack, true
+		}
+	}
+	return availableStack{}, false
+}
+
+// selectStack lets the user pick a stack, the list can be filtered by typing '/'.
+func selectStack(label string, stacks []availableStack) (string, error) {
+	prompt := promptui.Select{
+		Label: label + " (type / to search)",
+		Items: stacks,
+		Size:  10,
+		Templates: &promptui.SelectTemplates{
+			Active:   fmt.Sprintf("%s {{ .Label | cyan }}", promptui.IconSelect),
+			Inactive: "  {{ .Label }}",
+			Selected: "Stack: {{ .ID | green }}",
+			Details: `
+{{ "Status:" | faint }}	{{ .Status }}
+{{ "Preinstalled tools:" | faint }}	{{ .SystemReportURL }}`,
+		},
+		Searcher: func(input string, index int) bool {
+			input = strings.ToLower(strings.TrimSpace(input))
+			return strings.Contains(strings.ToLower(stacks[index].Label()), input)
+		},
+	}
+
+	i, _, err := prompt.Run()
+	if err != nil {
+		return "", err
+	}
+
+	return stacks[i].ID, nil
+}
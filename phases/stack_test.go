@@ -0,0 +1,97 @@
+package phases
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_defaultStack(t *testing.T) {
+	availableStacks := []availableStack{
+		{ID: "linux-docker-android-20.04", Status: stackStatusDeprecated},
+		{ID: "linux-docker-android-22.04", Status: stackStatusStable},
+		{ID: "osx-xcode-15.4.x", Status: stackStatusStable},
+		{ID: "osx-xcode-16.0.x", Status: stackStatusStable},
+		{ID: "osx-xcode-16.2.x", Status: stackStatusStable},
+		{ID: "osx-xcode-16.3.x", Status: stackStatusEdge},
+		{ID: "osx-xcode-edge", Status: stackStatusEdge},
+		{ID: "ubuntu-jammy-22.04-bitrise-2024", Status: stackStatusStable},
+		{ID: "ubuntu-noble-24.04-bitrise-2025-android", Status: stackStatusStable},
+		{ID: "ubuntu-plucky-25.04-bitrise-2025-android", Status: stackStatusDeprecated},
+	}
+
+	tests := []struct {
+		name            string
+		projectType     string
+		stepIDs         []string
+		availableStacks []availableStack
+		want            string
+	}{
+		{
+			name:            "latest stable Xcode stack",
+			projectType:     "ios",
+			availableStacks: availableStacks,
+			want:            "osx-xcode-16.2.x",
+		},
+		{
+			name:            "latest Ubuntu Android stack",
+			projectType:     "android",
+			availableStacks: availableStacks,
+			want:            "ubuntu-noble-24.04-bitrise-2025-android",
+		},
+		{
+			name:            "steps requiring macOS",
+			projectType:     "other",
+			stepIDs:         []string{"git-clone", "xcode-archive"},
+			availableStacks: availableStacks,
+			want:            "osx-xcode-16.2.x",
+		},
+		{
+			name:            "unknown project type",
+			projectType:     "web",
+			availableStacks: availableStacks,
+			want:            "",
+		},
+		{
+			name:            "no matching stack available",
+			projectType:     "flutter",
+			availableStacks: []availableStack{{ID: "linux-docker-android-22.04", Status: stackStatusStable}},
+			want:            "",
+		},
+		{
+			name:            "only edge Xcode stacks",
+			projectType:     "ios",
+			availableStacks: []availableStack{{ID: "osx-xcode-16.3.x", Status: stackStatusEdge}, {ID: "osx-xcode-16.0.x", Status: stackStatusEdge}},
+			want:            "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, defaultStack(defaultStackOS(tt.projectType, tt.stepIDs), tt.availableStacks))
+		})
+	}
+}
+
+func Test_parseAvailableStacks(t *testing.T) {
+	response := map[string]json.RawMessage{
+		"linux-docker-android-22.04": json.RawMessage(`{"title": "Ubuntu 22.04 with Android SDK"}`),
+		"osx-xcode-15.4.x":           json.RawMessage(`{"title": "Xcode 15.4.x", "os": "macOS", "status": "deprecated"}`),
+		"osx-xcode-16.2.x":           json.RawMessage(`{"title": "Xcode 16.2.x", "os": "macOS"}`),
+		"osx-xcode-edge":             json.RawMessage(`{}`),
+		"osx-xcode-16.0.x":           json.RawMessage(`"unexpected"`),
+	}
+
+	stacks := parseAvailableStacks(response)
+
+	require.Equal(t, []string{
+		"osx-xcode-16.2.x",
+		"osx-xcode-16.0.x",
+		"osx-xcode-edge",
+		"osx-xcode-15.4.x",
+		"linux-docker-android-22.04",
+	}, stackIDs(stacks))
+	require.Equal(t, availableStack{ID: "osx-xcode-edge", OS: stackOSMacOS, Status: stackStatusEdge}, stacks[2])
+	require.Equal(t, "[macOS] osx-xcode-15.4.x - Xcode 15.4.x (deprecated)", stacks[3].Label())
+	require.Equal(t, "[Linux] linux-docker-android-22.04 - Ubuntu 22.04 with Android SDK", stacks[4].Label())
+}
@@ -0,0 +1,235 @@
+package phases
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/bitrise/v2/models"
+	"github.com/bitrise-io/go-utils/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	triggerMapKey = "trigger_map"
+	triggersKey   = "triggers"
+)
+
+// Target based triggers (the triggers property of workflows and pipelines) are only supported from this format version on,
+// older configs use the trigger_map.
+const targetBasedTriggersFormatVersion = 17
+
+// hasTriggers reports whether any git event starts a build of the bitrise.yml.
+func hasTriggers(bitriseYML models.BitriseDataModel) bool {
+	if len(bitriseYML.TriggerMap) > 0 {
+		return true
+	}
+	isSet := func(triggers models.Triggers) bool {
+		return len(triggers.PushTriggers) > 0 || len(triggers.PullRequestTriggers) > 0 || len(triggers.TagTriggers) > 0
+	}
+	for _, workflow := range bitriseYML.Workflows {
+		if isSet(workflow.Triggers) {
+			return true
+		}
+	}
+	for _, pipeline := range bitriseYML.Pipelines {
+		if isSet(pipeline.Triggers) {
+			return true
+		}
+	}
+	return false
+}
+
+func supportsTargetBasedTriggers(formatVersion string) bool {
+	major, err := strconv.Atoi(strings.SplitN(formatVersion, ".", 2)[0])
+	if err != nil {
+		return false
+	}
+	return major >= targetBasedTriggersFormatVersion
+}
+
+// targetBasedTriggers starts the target on pushes to the branch and on every pull request.
+func targetBasedTriggers(branch string) models.Triggers {
+	return models.Triggers{
+		PushTriggers:        []models.PushGitEventTriggerItem{{Branch: branch}},
+		PullRequestTriggers: []models.PullRequestGitEventTriggerItem{{SourceBranch: "*"}},
+	}
+}
+
+// triggerMap starts the target on pushes to the branch and on every pull request.
+func triggerMap(target BuildTarget, branch string) models.TriggerMapModel {
+	return models.TriggerMapModel{
+		{PushBranch: branch, WorkflowID: target.WorkflowID, PipelineID: target.PipelineID},
+		{PullRequestSourceBranch: "*", WorkflowID: target.WorkflowID, PipelineID: target.PipelineID},
+	}
+}
+
+// setTriggers adds the triggers to the bitrise.yml data model.
+func setTriggers(bitriseYML *models.BitriseDataModel, target BuildTarget, branch string) {
+	if !supportsTargetBasedTriggers(bitriseYML.FormatVersion) {
+		bitriseYML.TriggerMap = triggerMap(target, branch)
+		return
+	}
+
+	if target.PipelineID != "" {
+		pipeline := bitriseYML.Pipelines[target.PipelineID]
+		pipeline.Triggers = targetBasedTriggers(branch)
+		bitriseYML.Pipelines[target.PipelineID] = pipeline
+	} else {
+		workflow := bitriseYML.Workflows[target.WorkflowID]
+		workflow.Triggers = targetBasedTriggers(branch)
+		bitriseYML.Workflows[target.WorkflowID] = workflow
+	}
+}
+
+// triggersYAML returns the YAML lines of the value, indented with the given prefix.
+func triggersYAML(key string, value interface{}, indent string) ([]string, error) {
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string]interface{}{key: value}); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		lines = append(lines, indent+line)
+	}
+	return lines, nil
+}
+
+// addTriggersFallback adds the triggers by re-encoding the YAML node tree,
+// used when the target can not be patched line by line (e.g. flow style mappings).
+func addTriggersFallback(doc *yaml.Node, path []string, key string, value interface{}) ([]byte, error) {
+	var valueNode yaml.Node
+	if err := valueNode.Encode(value); err != nil {
+		return nil, err
+	}
+
+	parent := doc.Content[0]
+	for _, k := range path {
+		_, child := mappingKeyValue(parent, k)
+		if child == nil {
+			return nil, fmt.Errorf("%s not found in bitrise.yml", strings.Join(path, "."))
+		}
+		if child.Kind != yaml.MappingNode {
+			*child = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		parent = child
+	}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &valueNode)
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// addTriggers adds the triggers of the target to the given bitrise.yml content,
+// only the new lines are inserted, the rest of the file is kept as is.
+func addTriggers(content []byte, target BuildTarget, branch string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse bitrise.yml: %s", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("bitrise.yml root is not a mapping")
+	}
+	root := doc.Content[0]
+
+	var formatVersion string
+	if _, node := mappingKeyValue(root, "format_version"); node != nil {
+		formatVersion = node.Value
+	}
+
+	if !supportsTargetBasedTriggers(formatVersion) {
+		value := triggerMap(target, branch)
+		if !isBlockMapping(root) {
+			return addTriggersFallback(&doc, nil, triggerMapKey, value)
+		}
+
+		indent := ""
+		if len(root.Content) > 0 {
+			indent = strings.Repeat(" ", root.Content[0].Column-1)
+		}
+		triggerLines, err := triggersYAML(triggerMapKey, value, indent)
+		if err != nil {
+			return nil, err
+		}
+
+		lines := newBitriseYMLLines(content)
+		lines.insertAfter(len(lines.lines), triggerLines...)
+		return lines.bytes(), nil
+	}
+
+	path := []string{"workflows", target.WorkflowID}
+	if target.PipelineID != "" {
+		path = []string{"pipelines", target.PipelineID}
+	}
+	value := targetBasedTriggers(branch)
+
+	parent := root
+	var keyNode, valueNode *yaml.Node
+	for _, k := range path {
+		if !isBlockMapping(parent) {
+			return addTriggersFallback(&doc, path, triggersKey, value)
+		}
+		keyNode, valueNode = mappingKeyValue(parent, k)
+		if valueNode == nil {
+			return nil, fmt.Errorf("%s not found in bitrise.yml", strings.Join(path, "."))
+		}
+		parent = valueNode
+	}
+	if !isBlockMapping(valueNode) && !isEmpty(valueNode) {
+		return addTriggersFallback(&doc, path, triggersKey, value)
+	}
+
+	triggerLines, err := triggersYAML(triggersKey, value, childIndent(keyNode, valueNode))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := newBitriseYMLLines(content)
+	lines.insertAfter(keyNode.Line, triggerLines...)
+	return lines.bytes(), nil
+}
+
+// Triggers offers to generate the triggers of the first build's target if the bitrise.yml has none,
+// otherwise the registered webhook would not start any build.
+func Triggers(bitriseYML models.BitriseDataModel, content []byte, target BuildTarget, branch string) (models.BitriseDataModel, []byte, error) {
+	if hasTriggers(bitriseYML) {
+		return bitriseYML, content, nil
+	}
+
+	fmt.Println()
+	log.Infof("TRIGGERS")
+	log.Warnf("The bitrise.yml has no triggers, git events will not start builds.")
+
+	generate, err := askYesNo(fmt.Sprintf("Do you want to run the %s on pushes to %s and on pull requests?", target, branch), "Generate triggers")
+	if err != nil {
+		return models.BitriseDataModel{}, nil, err
+	}
+	if !generate {
+		log.Printf("Skipping trigger generation, you can add triggers in the Workflow Editor later.")
+		return bitriseYML, content, nil
+	}
+
+	if content != nil {
+		if content, err = addTriggers(content, target, branch); err != nil {
+			return models.BitriseDataModel{}, nil, fmt.Errorf("failed to add triggers to bitrise.yml, error: %s", err)
+		}
+	}
+	setTriggers(&bitriseYML, target, branch)
+
+	return bitriseYML, content, nil
+}
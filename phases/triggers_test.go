@@ -0,0 +1,134 @@
+package phases
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/bitrise/v2/bitrise"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_addTriggers(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		target  BuildTarget
+		want    string
+	}{
+		{
+			name: "trigger map",
+			content: `format_version: "13"
+# workflows
+workflows:
+  primary:
+    steps:
+    - git-clone@8: {}
+`,
+			target: BuildTarget{WorkflowID: "primary"},
+			want: `format_version: "13"
+# workflows
+workflows:
+  primary:
+    steps:
+    - git-clone@8: {}
+trigger_map:
+  - workflow: primary
+    push_branch: main
+  - workflow: primary
+    pull_request_source_branch: '*'
+`,
+		},
+		{
+			name: "workflow triggers",
+			content: `format_version: "23"
+workflows:
+    primary:
+        steps:
+        - git-clone@8: {}
+`,
+			target: BuildTarget{WorkflowID: "primary"},
+			want: `format_version: "23"
+workflows:
+    primary:
+        triggers:
+          push:
+            - branch: main
+          pull_request:
+            - source_branch: '*'
+        steps:
+        - git-clone@8: {}
+`,
+		},
+		{
+			name: "pipeline triggers",
+			content: `format_version: "23"
+pipelines:
+  ci:
+    workflows:
+      primary: {}
+workflows:
+  primary:
+    steps:
+    - git-clone@8: {}
+`,
+			target: BuildTarget{PipelineID: "ci"},
+			want: `format_version: "23"
+pipelines:
+  ci:
+    triggers:
+      push:
+        - branch: main
+      pull_request:
+        - source_branch: '*'
+    workflows:
+      primary: {}
+workflows:
+  primary:
+    steps:
+    - git-clone@8: {}
+`,
+		},
+		{
+			name: "flow style workflow",
+			content: `format_version: "23"
+workflows:
+  primary: {steps: [{git-clone@8: {}}]}
+`,
+			target: BuildTarget{WorkflowID: "primary"},
+			want: `format_version: "23"
+workflows:
+  primary: {steps: [{git-clone@8: {}}], triggers: {push: [{branch: main}], pull_request: [{source_branch: '*'}]}}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := addTriggers([]byte(tt.content), tt.target, "main")
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+
+			bitriseYML, _, err := bitrise.ConfigModelFromYAMLBytes(got)
+			require.NoError(t, err)
+			require.True(t, hasTriggers(bitriseYML))
+		})
+	}
+}
+
+func Test_setTriggers(t *testing.T) {
+	bitriseYML, _, err := bitrise.ConfigModelFromYAMLBytes([]byte(`format_version: "13"
+workflows:
+  primary:
+    steps:
+    - git-clone@8: {}
+`))
+	require.NoError(t, err)
+	require.False(t, hasTriggers(bitriseYML))
+
+	setTriggers(&bitriseYML, BuildTarget{WorkflowID: "primary"}, "main")
+	require.Len(t, bitriseYML.TriggerMap, 2)
+	require.Equal(t, "main", bitriseYML.TriggerMap[0].PushBranch)
+
+	bitriseYML.FormatVersion = "23"
+	bitriseYML.TriggerMap = nil
+	setTriggers(&bitriseYML, BuildTarget{WorkflowID: "primary"}, "main")
+	require.Equal(t, targetBasedTriggers("main"), bitriseYML.Workflows["primary"].Triggers)
+}
@@ -0,0 +1,219 @@
+package sshutil
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/manifoldco/promptui"
+	"github.com/skeema/knownhosts"
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultHostKeyAlgorithms are offered to hosts not yet listed in the known_hosts file.
+var defaultHostKeyAlgorithms = []string{
+	ssh.KeyAlgoED25519,
+	ssh.KeyAlgoECDSA256,
+	ssh.KeyAlgoECDSA384,
+	ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoRSASHA512,
+	ssh.KeyAlgoRSASHA256,
+	ssh.KeyAlgoRSA,
+}
+
+type acceptedHostKey struct {
+	hostname string
+	key      ssh.PublicKey
+}
+
+// KnownHosts verifies the host key of SSH git servers against a known_hosts file.
+// Host keys not found in the file are shown to the user for confirmation in interactive mode,
+// and rejected otherwise.
+type KnownHosts struct {
+	Path string
+
+	interactive bool
+	db          *knownhosts.HostKeyDB
+	accepted    []acceptedHostKey
+}
+
+// DefaultKnownHostsPath returns the path of the user's known_hosts file (~/.ssh/known_hosts).
+func DefaultKnownHostsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".ssh", "known_hosts"), nil
+}
+
+// NewKnownHosts loads the given known_hosts file. A missing file is treated as empty in interactive mode.
+func NewKnownHosts(pth string, interactive bool) (*KnownHosts, error) {
+	k := &KnownHosts{
+		Path:        pth,
+		interactive: interactive,
+	}
+
+	if _, err := os.Stat(pth); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if !interactive {
+			return nil, fmt.Errorf("known_hosts file (%s) does not exist", pth)
+		}
+		log.Debugf("known_hosts file (%s) does not exist, all hosts are unknown", pth)
+		return k, nil
+	}
+
+	db, err := knownhosts.NewDB(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse known_hosts file (%s), error: %s", pth, err)
+	}
+	k.db = db
+
+	return k, nil
+}
+
+func (k *KnownHosts) isAccepted(hostname string, key ssh.PublicKey) bool {
+	for _, accepted := range k.accepted {
+		if accepted.hostname == knownhosts.Normalize(hostname) && bytes.Equal(accepted.key.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *KnownHosts) accept(hostname string, key ssh.PublicKey) {
+	if k.isAccepted(hostname, key) {
+		return
+	}
+	k.accepted = append(k.accepted, acceptedHostKey{
+		hostname: knownhosts.Normalize(hostname),
+		key:      key,
+	})
+}
+
+func confirmHostKey(hostname string, key ssh.PublicKey) (bool, error) {
+	const (
+		optionYes = "Yes"
+		optionNo  = "No"
+	)
+
+	log.Warnf("The authenticity of host '%s' can't be established.", knownhosts.Normalize(hostname))
+	log.Printf("%s key fingerprint is %s", key.Type(), ssh.FingerprintSHA256(key))
+
+	prompt := promptui.Select{
+		Label: "Are you sure you want to trust this host?",
+		Items: []string{optionYes, optionNo},
+		Templates: &promptui.SelectTemplates{
+			Label:    fmt.Sprintf("%s {{.}} ", promptui.IconInitial),
+			Selected: "Trust host: {{ . | green }}",
+		},
+	}
+
+	_, answer, err := prompt.Run()
+	if err != nil {
+		return false, fmt.Errorf("scan user input: %s", err)
+	}
+
+	return answer == optionYes, nil
+}
+
+// HostKeyCallback returns an ssh.HostKeyCallback checking the host keys against the known_hosts file
+// and the host keys already accepted by the user.
+func (k *KnownHosts) HostKeyCallback() ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if k.isAccepted(hostname, key) {
+			return nil
+		}
+
+		if k.db != nil {
+			err := k.db.HostKeyCallback()(hostname, remote, key)
+			if err == nil {
+				k.accept(hostname, key)
+				return nil
+			}
+			if knownhosts.IsHostKeyChanged(err) {
+				return fmt.Errorf("host key of %s (%s) does not match the one in %s, the connection might be intercepted", hostname, ssh.FingerprintSHA256(key), k.Path)
+			}
+			if !knownhosts.IsHostUnknown(err) {
+				return err
+			}
+		}
+
+		if !k.interactive {
+			return fmt.Errorf("host %s (%s key fingerprint %s) is not found in %s", hostname, key.Type(), ssh.FingerprintSHA256(key), k.Path)
+		}
+
+		trusted, err := confirmHostKey(hostname, key)
+		if err != nil {
+			return err
+		}
+		if !trusted {
+			return fmt.Errorf("host key of %s was rejected", hostname)
+		}
+
+		k.accept(hostname, key)
+		return nil
+	}
+}
+
+// HostKeyAlgorithms returns the host key algorithms to negotiate with the given host,
+// preferring the key types already listed in the known_hosts file.
+func (k *KnownHosts) HostKeyAlgorithms(hostWithPort string) []string {
+	if k.db != nil {
+		if algorithms := k.db.HostKeyAlgorithms(hostWithPort); len(algorithms) > 0 {
+			return algorithms
+		}
+	}
+	return defaultHostKeyAlgorithms
+}
+
+// AcceptedHostKeys returns the host keys used during the session in known_hosts format.
+func (k *KnownHosts) AcceptedHostKeys() []string {
+	var lines []string
+	for _, accepted := range k.accepted {
+		lines = append(lines, knownhosts.Line([]string{accepted.hostname}, accepted.key))
+	}
+	return lines
+}
+
+// hostKeyVerifyingAuth sets the host key algorithms explicitly, so go-git does not
+// fall back to looking up the default known_hosts files.
+type hostKeyVerifyingAuth struct {
+	*gitssh.PublicKeys
+	hostKeyAlgorithms []string
+}
+
+// ClientConfig ...
+func (a *hostKeyVerifyingAuth) ClientConfig() (*ssh.ClientConfig, error) {
+	config, err := a.PublicKeys.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.HostKeyAlgorithms = a.hostKeyAlgorithms
+	return config, nil
+}
+
+func (k *KnownHosts) auth(publicKeys *gitssh.PublicKeys, url string) (gitssh.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	port := endpoint.Port
+	if port == 0 {
+		port = 22
+	}
+
+	publicKeys.HostKeyCallback = k.HostKeyCallback()
+
+	return &hostKeyVerifyingAuth{
+		PublicKeys:        publicKeys,
+		hostKeyAlgorithms: k.HostKeyAlgorithms(net.JoinHostPort(endpoint.Host, strconv.Itoa(port))),
+	}, nil
+}
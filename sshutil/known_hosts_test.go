@@ -0,0 +1,55 @@
+package sshutil
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skeema/knownhosts"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+	return key
+}
+
+func TestKnownHosts_HostKeyCallback(t *testing.T) {
+	knownKey := newHostKey(t)
+	pth := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(pth, []byte(knownhosts.Line([]string{"github.com"}, knownKey)+"\n"), 0600))
+
+	hostKeys, err := NewKnownHosts(pth, false)
+	require.NoError(t, err)
+
+	remote := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22}
+	callback := hostKeys.HostKeyCallback()
+
+	require.NoError(t, callback("github.com:22", remote, knownKey))
+	require.Equal(t, []string{knownhosts.Line([]string{"github.com"}, knownKey)}, hostKeys.AcceptedHostKeys())
+
+	require.Error(t, callback("github.com:22", remote, newHostKey(t)), "changed host key")
+	require.Error(t, callback("git.example.com:22", remote, knownKey), "unknown host in non-interactive mode")
+	require.Len(t, hostKeys.AcceptedHostKeys(), 1)
+
+	require.Equal(t, []string{ssh.KeyAlgoED25519}, hostKeys.HostKeyAlgorithms("github.com:22"))
+	require.Equal(t, defaultHostKeyAlgorithms, hostKeys.HostKeyAlgorithms("git.example.com:22"))
+}
+
+func TestNewKnownHosts_missingFile(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "known_hosts")
+
+	_, err := NewKnownHosts(pth, false)
+	require.Error(t, err)
+
+	hostKeys, err := NewKnownHosts(pth, true)
+	require.NoError(t, err)
+	require.Empty(t, hostKeys.AcceptedHostKeys())
+}
@@ -13,12 +13,19 @@ import (
 	"github.com/go-git/go-git/v5/storage/memory"
 )
 
-// ValidatePrivateKey checks if can connect to a repository with a given private key
-func ValidatePrivateKey(privateKey []byte, username string, url string) (bool, error) {
-	SSHAuth, err := ssh.NewPublicKeys(username, privateKey, "")
+// ValidatePrivateKey checks if can connect to a repository with a given private key.
+// If hostKeys is nil, the host key is verified by go-git's default known_hosts lookup.
+func ValidatePrivateKey(privateKey []byte, username string, url string, hostKeys *KnownHosts) (bool, error) {
+	publicKeys, err := ssh.NewPublicKeys(username, privateKey, "")
 	if err != nil {
 		return false, err
 	}
+	var SSHAuth ssh.AuthMethod = publicKeys
+	if hostKeys != nil {
+		if SSHAuth, err = hostKeys.auth(publicKeys, url); err != nil {
+			return false, err
+		}
+	}
 	var b bytes.Buffer
 	if _, err = git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
 		Auth:              SSHAuth,
@@ -44,6 +51,7 @@ type SSHRepo struct {
 	Keys     SSHKeyPair
 	Username string
 	URL      string
+	HostKeys *KnownHosts
 }
 
 // ValidateSSHAddedManually checks that a generated public key is added to the git service provider
@@ -59,7 +67,7 @@ func ValidateSSHAddedManually(repo SSHRepo) error {
 			return err
 		}
 
-		if valid, err := ValidatePrivateKey(repo.Keys.PrivateKey, repo.Username, repo.URL); !valid {
+		if valid, err := ValidatePrivateKey(repo.Keys.PrivateKey, repo.Username, repo.URL, repo.HostKeys); !valid {
 			log.Errorf("Could not connect to repository with private key, error: %s", err)
 			return err
 		}